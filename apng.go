@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// APNGEncoder streams frames to an animated png, 8 bits per channel are kept exactly.
+// The frame count in acTL is patched in on Close.
+type APNGEncoder struct {
+	name string
+	file *os.File
+	w    *bufio.Writer
+
+	width, height int
+	frames        uint32
+	sequence      uint32
+	err           error
+
+	// offset of the acTL chunk data
+	actl int64
+
+	// delays are milliseconds, carry rounding so playback doesn't drift
+	elapsed time.Duration
+	written int
+
+	raw, prev, filtered []byte
+	zbuf                bytes.Buffer
+	z                   *zlib.Writer
+}
+
+func NewAPNGEncoder(name string, width, height int) (*APNGEncoder, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &APNGEncoder{
+		name:   name,
+		file:   f,
+		w:      bufio.NewWriter(f),
+		width:  width,
+		height: height,
+	}
+
+	enc.z, _ = zlib.NewWriterLevel(&enc.zbuf, zlib.BestSpeed)
+
+	enc.write([]byte("\x89PNG\r\n\x1a\n"))
+
+	// 8 bit rgba, no interlace
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8] = 8
+	ihdr[9] = 6
+	enc.chunk("IHDR", ihdr)
+
+	// frame count unknown until Close, loop forever
+	enc.actl = 8 + 12 + 13 + 8
+	enc.chunk("acTL", make([]byte, 8))
+
+	if enc.err != nil {
+		f.Close()
+		return nil, enc.err
+	}
+
+	return enc, nil
+}
+
+func (self *APNGEncoder) write(b []byte) {
+	if self.err == nil {
+		_, self.err = self.w.Write(b)
+	}
+}
+
+func (self *APNGEncoder) chunk(name string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc.Sum32())
+
+	self.write(header)
+	self.write(data)
+	self.write(sum)
+}
+
+func (self *APNGEncoder) Encode(img *image.RGBA, duration time.Duration) error {
+	self.elapsed += duration
+	delay := int(math.Round(float64(self.elapsed)/float64(time.Millisecond))) - self.written
+	if delay < 1 {
+		delay = 1
+	}
+
+	num, den := apngDelay(delay)
+	self.written += int(num) * (1000 / int(den))
+
+	// frame control, full size at the origin
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], self.sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(self.width))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(self.height))
+	binary.BigEndian.PutUint16(fctl[20:], num)
+	binary.BigEndian.PutUint16(fctl[22:], den)
+	self.sequence++
+	self.chunk("fcTL", fctl)
+
+	data, err := self.compress(img)
+	if err != nil {
+		return err
+	}
+
+	if self.frames == 0 {
+		self.chunk("IDAT", data)
+	} else {
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, self.sequence)
+		copy(fdat[4:], data)
+		self.sequence++
+		self.chunk("fdAT", fdat)
+	}
+
+	self.frames++
+	return self.err
+}
+
+// apngDelay a delay of ms milliseconds as a uint16 fraction of a second, pauses too long
+// for milliseconds are counted in centiseconds, tenths or whole seconds
+func apngDelay(ms int) (num, den uint16) {
+	for _, unit := range []int{1, 10, 100, 1000} {
+		n := (ms + unit/2) / unit
+		if n <= math.MaxUint16 {
+			return uint16(n), uint16(1000 / unit)
+		}
+	}
+
+	return math.MaxUint16, 1
+}
+
+// compress filter each row and deflate the frame
+func (self *APNGEncoder) compress(img *image.RGBA) ([]byte, error) {
+	stride := self.width * 4
+	if self.raw == nil {
+		self.raw = make([]byte, stride)
+		self.prev = make([]byte, stride)
+		self.filtered = make([]byte, 5*(1+stride))
+	}
+
+	for i := range self.prev {
+		self.prev[i] = 0
+	}
+
+	self.zbuf.Reset()
+	self.z.Reset(&self.zbuf)
+
+	for y := 0; y < self.height; y++ {
+		copy(self.raw, img.Pix[y*img.Stride:y*img.Stride+stride])
+		if _, err := self.z.Write(filterPNGRow(self.raw, self.prev, self.filtered)); err != nil {
+			return nil, err
+		}
+
+		self.raw, self.prev = self.prev, self.raw
+	}
+
+	if err := self.z.Close(); err != nil {
+		return nil, err
+	}
+
+	return self.zbuf.Bytes(), nil
+}
+
+// filterPNGRow pick the row filter with the smallest sum of absolute differences.
+// Rows are 4 bytes per pixel, scratch holds 5 filtered rows.
+func filterPNGRow(cur, prev, scratch []byte) []byte {
+	n := len(cur) + 1
+	best, bestSum := 0, -1
+	for f := 0; f < 5; f++ {
+		out := scratch[f*n : (f+1)*n]
+		out[0] = byte(f)
+		sum := 0
+		for i := range cur {
+			var a, c byte
+			b := prev[i]
+			if i >= 4 {
+				a, c = cur[i-4], prev[i-4]
+			}
+
+			var v byte
+			switch f {
+			case 0:
+				v = cur[i]
+			case 1:
+				v = cur[i] - a
+			case 2:
+				v = cur[i] - b
+			case 3:
+				v = cur[i] - byte((int(a)+int(b))/2)
+			case 4:
+				v = cur[i] - paeth(a, b, c)
+			}
+
+			out[i+1] = v
+			sum += int(int8(v)) * (1 - 2*int(v>>7))
+		}
+
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = f, sum
+		}
+	}
+
+	return scratch[best*n : (best+1)*n]
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+
+	if pb <= pc {
+		return b
+	}
+
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+// Close finish the file, a recording without frames is removed since a png needs an image
+func (self *APNGEncoder) Close() error {
+	if self.frames == 0 {
+		self.file.Close()
+		os.Remove(self.name)
+		return fmt.Errorf("apng %v: no frames recorded", self.name)
+	}
+
+	self.chunk("IEND", nil)
+	if self.err == nil {
+		self.err = self.w.Flush()
+	}
+
+	// patch in the frame count
+	if self.err == nil {
+		actl := make([]byte, 16)
+		copy(actl, "acTL")
+		binary.BigEndian.PutUint32(actl[4:], self.frames)
+		binary.BigEndian.PutUint32(actl[12:], crc32.ChecksumIEEE(actl[:12]))
+		_, self.err = self.file.WriteAt(actl[4:], self.actl)
+	}
+
+	if err := self.file.Close(); self.err == nil {
+		self.err = err
+	}
+
+	return self.err
+}
+
+func (self *APNGEncoder) Name() string {
+	return self.name
+}
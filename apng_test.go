@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pngChunks the chunks of a png file in order
+func pngChunks(t *testing.T, data []byte) (names []string, chunks map[string][][]byte) {
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Fatal("missing png signature")
+	}
+
+	chunks = map[string][][]byte{}
+	for data = data[8:]; len(data) >= 12; {
+		n := binary.BigEndian.Uint32(data)
+		name := string(data[4:8])
+		body := data[8 : 8+n]
+		if crc := binary.BigEndian.Uint32(data[8+n:]); crc != crc32.ChecksumIEEE(data[4:8+n]) {
+			t.Errorf("%v: bad crc", name)
+		}
+
+		names = append(names, name)
+		chunks[name] = append(chunks[name], body)
+		data = data[12+n:]
+	}
+
+	return names, chunks
+}
+
+func TestAPNGEncoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		// delay num/den of each frame
+		delays [][2]uint16
+	}{
+		{
+			name:      "one frame",
+			durations: []time.Duration{time.Second / 60},
+			delays:    [][2]uint16{{17, 1000}},
+		},
+		{
+			name:      "60 fps carries rounding",
+			durations: []time.Duration{time.Second / 60, time.Second / 60, time.Second / 60},
+			delays:    [][2]uint16{{17, 1000}, {16, 1000}, {17, 1000}},
+		},
+		{
+			name:      "long pause",
+			durations: []time.Duration{time.Second, 2 * time.Minute, 3 * time.Hour},
+			delays:    [][2]uint16{{1000, 1000}, {12000, 100}, {10800, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "a.png")
+			enc, err := NewAPNGEncoder(name, 3, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+			for i, d := range tt.durations {
+				img.Pix[0] = byte(i)
+				if err := enc.Encode(img, d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names, chunks := pngChunks(t, data)
+			if names[0] != "IHDR" || names[1] != "acTL" || names[len(names)-1] != "IEND" {
+				t.Errorf("chunk order %v", names)
+			}
+
+			if frames := binary.BigEndian.Uint32(chunks["acTL"][0]); int(frames) != len(tt.durations) {
+				t.Errorf("acTL has %v frames, want %v", frames, len(tt.durations))
+			}
+
+			if len(chunks["IDAT"]) != 1 || len(chunks["fdAT"]) != len(tt.durations)-1 {
+				t.Errorf("%v IDAT and %v fdAT chunks for %v frames", len(chunks["IDAT"]), len(chunks["fdAT"]), len(tt.durations))
+			}
+
+			for i, fctl := range chunks["fcTL"] {
+				got := [2]uint16{binary.BigEndian.Uint16(fctl[20:]), binary.BigEndian.Uint16(fctl[22:])}
+				if got != tt.delays[i] {
+					t.Errorf("frame %v: delay %v/%v, want %v/%v", i, got[0], got[1], tt.delays[i][0], tt.delays[i][1])
+				}
+			}
+
+			// plain png decoders see the first frame
+			first, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if r, _, _, _ := first.At(0, 0).RGBA(); r != 0 {
+				t.Errorf("first frame pixel is %v, want 0", r>>8)
+			}
+		})
+	}
+}
+
+func TestAPNGEncoderNoFrames(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.png")
+	enc, err := NewAPNGEncoder(name, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err == nil {
+		t.Error("closing without frames succeeded")
+	}
+
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("invalid png without frames was left behind: %v", err)
+	}
+}
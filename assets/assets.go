@@ -1,9 +1,16 @@
 package assets
 
 import (
-	_ "embed"
+	"embed"
+	"io/fs"
 )
 
+//go:embed shaders
+var shaders embed.FS
+
+// ShaderFS every embedded shader, used to resolve #include
+var ShaderFS, _ = fs.Sub(shaders, "shaders")
+
 // basic shaders
 
 //go:embed shaders/vertex.glsl
@@ -23,6 +30,10 @@ var GaussYShader string
 //go:embed shaders/rgba_sampler.glsl
 var RGBAShader string
 
+// image overlays
+//go:embed shaders/overlay.glsl
+var OverlayShader string
+
 // 3 channel mixers
 //go:embed shaders/rgb_sampler.glsl
 var RGBShader string
@@ -0,0 +1,15 @@
+// sample state using uv and apply GRADIENT coloring, define GRADIENT before including
+uniform int index;
+uniform sampler2D state;
+uniform vec2 scale;
+uniform float alpha;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+void main() {
+  vec4 tex = texture(state, gl_FragCoord.xy / scale, 0);
+  vec4 color = vec4(GRADIENT(tex[index]), 1.0);
+  outputColor = vec4(color.rgb, 1.0 - color.a * alpha);
+}
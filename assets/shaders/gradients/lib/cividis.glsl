@@ -0,0 +1,17 @@
+// cividis color map, t in [0, 1]
+#ifndef GRADIENT_CIVIDIS
+#define GRADIENT_CIVIDIS
+
+vec3 cividis(float t) {
+  float r = round(-4.54 - t*(35.34-t*(2381.73-t*(6402.7-t*(7024.72-t*2710.57)))));
+  float g = round(32.49 + t*(170.73+t*(52.82-t*(131.46-t*(176.58-t*67.37)))));
+  float b = round(81.24 + t*(442.36-t*(2482.43-t*(6167.24-t*(6614.94-t*2475.67)))));
+
+  return vec3(
+    r / 255, 
+    g / 255, 
+    b / 255
+  );
+}
+
+#endif
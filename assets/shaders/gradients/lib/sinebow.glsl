@@ -0,0 +1,19 @@
+// sinebow color map, t in [0, 1]
+#ifndef GRADIENT_SINEBOW
+#define GRADIENT_SINEBOW
+
+const float pi = 3.141592653589793238462643383;
+const float pi1_3 = pi / 3;
+const float pi2_3 = pi * 2 / 3;
+
+vec3 sinebow(float t) {
+  t = (0.5 - t) * pi;
+
+  return vec3(
+    pow(sin(t), 2),
+    pow(sin(t+pi1_3), 2),
+    pow(sin(t+pi2_3), 2)
+  );
+}
+
+#endif
@@ -0,0 +1,12 @@
+// turbo color map, t in [0, 1]
+#ifndef GRADIENT_TURBO
+#define GRADIENT_TURBO
+
+vec3 turbo(float x) {
+  float r = 0.1357 + x * ( 4.5974 - x * ( 42.3277 - x * ( 130.5887 - x * ( 150.5666 - x * 58.1375 ))));
+  float g = 0.0914 + x * ( 2.1856 + x * ( 4.8052 - x * ( 14.0195 - x * ( 4.2109 + x * 2.7747 ))));
+  float b = 0.1067 + x * ( 12.5925 - x * ( 60.1097 - x * ( 109.0745 - x * ( 88.5066 - x * 26.8183 ))));
+  return vec3(r,g,b);
+}
+
+#endif
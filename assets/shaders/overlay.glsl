@@ -0,0 +1,11 @@
+// draw an rgba image over the screen, images are stored top row first
+#version 410
+uniform sampler2D overlay;
+uniform vec2 scale;
+
+out vec4 outputColor;
+
+void main() {
+  vec2 uv = gl_FragCoord.xy / scale;
+  outputColor = texture(overlay, vec2(uv.x, 1.0 - uv.y));
+}
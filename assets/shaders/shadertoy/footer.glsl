@@ -0,0 +1,10 @@
+// shadertoy entry point, included after a pass's source
+void main() {
+  outputColor = vec4(0.0, 0.0, 0.0, 1.0);
+  mainImage(outputColor, gl_FragCoord.xy);
+
+#ifdef SHADERTOY_IMAGE
+  // the image pass is shown opaque
+  outputColor.a = 1.0;
+#endif
+}
@@ -16,15 +16,13 @@ func NewFramebuffer() *Framebuffer {
 	return &Framebuffer{fbo}
 }
 
-var LastActiveFramebuffer uint32 = 0
-
 func (self Framebuffer) Bind() {
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.Handle)
-	LastActiveFramebuffer = self.Handle
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.Handle)
 }
 
+// Unbind draw to the screen again, passes nested in others Push and Pop State instead
 func (self Framebuffer) Unbind() {
-	gl.BindFramebuffer(gl.FRAMEBUFFER, LastActiveFramebuffer)
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
 }
 
 type Renderbuffer struct {
@@ -39,27 +37,30 @@ func NewRenderbuffer(width, height int) *Renderbuffer {
 	var rbo uint32
 
 	fbo := NewFramebuffer()
+	defer State.Push().Pop()
 	fbo.Bind()
-	defer fbo.Unbind()
 
 	// color attachment0
 	img0 := image.NewRGBA(image.Rect(0, 0, width, height))
-	tex0 := LoadTexture(img0)
+	tex0 := LoadTexture(img0, LinearTextureOptions)
 	// tex0.Activate(gl.TEXTURE0)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, tex0.Handle, 0)
 
 	// color attachment1
 	img1 := image.NewRGBA(image.Rect(0, 0, width, height))
-	tex1 := LoadTexture(img1)
+	tex1 := LoadTexture(img1, LinearTextureOptions)
 	// tex1.Activate(gl.TEXTURE1)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, tex1.Handle, 0)
 
 	// create render buffer
 	gl.GenRenderbuffers(1, &rbo)
-	gl.BindRenderbuffer(gl.RENDERBUFFER, fbo.Handle)
-	defer gl.BindRenderbuffer(gl.RENDERBUFFER, LastActiveRenderbuffer)
+	State.BindRenderbuffer(rbo)
 	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH24_STENCIL8, int32(width), int32(height))
 	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, rbo)
+
+	// draw into both color attachments
+	buffers := []uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1}
+	gl.DrawBuffers(int32(len(buffers)), &buffers[0])
 	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
 		panic("ERROR: Framebuffer is not complete")
 	}
@@ -72,23 +73,162 @@ func NewRenderbuffer(width, height int) *Renderbuffer {
 	}
 }
 
-var LastActiveRenderbuffer uint32 = 0
-
 func (self Renderbuffer) Bind() {
-	gl.BindRenderbuffer(gl.RENDERBUFFER, self.Handle)
+	State.BindRenderbuffer(self.Handle)
 	self.Framebuffer.Bind()
-	LastActiveRenderbuffer = self.Handle
 }
 
 func (self Renderbuffer) Unbind() {
-	gl.BindFramebuffer(gl.RENDERBUFFER, LastActiveRenderbuffer)
+	State.BindRenderbuffer(0)
 	self.Framebuffer.Unbind()
 }
 
 func (self Renderbuffer) Resize(width, height int) {
 	self.Texture0.Resize(width, height)
 	self.Texture1.Resize(width, height)
+
+	defer State.Push().Pop()
 	self.Bind()
 	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH24_STENCIL8, int32(width), int32(height))
-	defer self.Unbind()
+}
+
+// PingPong two textures of the same format drawn into alternately through one framebuffer,
+// a pass reads Read while writing Write then swaps them.
+type PingPong struct {
+	Width, Height  int
+	InternalFormat int32
+	// Rescale contents to the new size on resize, otherwise they keep their position and are cropped
+	Rescale bool
+
+	textures [2]*Texture
+	*Framebuffer
+}
+
+// NewPingPong allocate a pair of empty textures with an internal format such as gl.RGBA8,
+// sampled with DefaultTextureOptions unless options are given
+func NewPingPong(width, height int, internalFormat int32, options ...TextureOptions) *PingPong {
+	return &PingPong{
+		Width:          width,
+		Height:         height,
+		InternalFormat: internalFormat,
+
+		textures: [2]*Texture{
+			NewTexture(width, height, internalFormat, options...),
+			NewTexture(width, height, internalFormat, options...),
+		},
+		Framebuffer: NewFramebuffer(),
+	}
+}
+
+// Read texture holding the last frame written
+func (self *PingPong) Read() *Texture {
+	return self.textures[0]
+}
+
+// Write texture the next frame is drawn to
+func (self *PingPong) Write() *Texture {
+	return self.textures[1]
+}
+
+// Copy draw a texture scaled to the size of the pair into both textures, e.g. one from LoadTextureFile
+func (self *PingPong) Copy(src *Texture) {
+	for _, tex := range self.textures {
+		blitTexture(src, tex, self.Width, self.Height)
+	}
+}
+
+// SetOptions change how both textures are sampled
+func (self *PingPong) SetOptions(options TextureOptions) {
+	for _, tex := range self.textures {
+		tex.SetOptions(options)
+	}
+}
+
+func (self *PingPong) Swap() {
+	self.textures[0], self.textures[1] = self.textures[1], self.textures[0]
+}
+
+// Bind draw into Write
+func (self *PingPong) Bind() {
+	self.Framebuffer.Bind()
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.Write().Handle, 0)
+	gl.Viewport(0, 0, int32(self.Width), int32(self.Height))
+}
+
+// Fill upload an image or texels the size of the pair to both textures, see Texture.Upload
+func (self *PingPong) Fill(data interface{}) {
+	for _, tex := range self.textures {
+		if err := tex.Upload(data); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Resize reallocate both textures, copying or rescaling their contents on the gpu
+func (self *PingPong) Resize(width, height int) {
+	if width == self.Width && height == self.Height {
+		return
+	}
+
+	for i, old := range self.textures {
+		tex := NewTexture(width, height, self.InternalFormat, old.Options)
+
+		dw, dh := self.Width, self.Height
+		if self.Rescale {
+			dw, dh = width, height
+		}
+
+		blitTexture(old, tex, dw, dh)
+		State.DeleteTextures(old.Handle)
+		self.textures[i] = tex
+	}
+
+	self.Width, self.Height = width, height
+}
+
+// ReadPixels copy Read back to the cpu, rows are bottom up as stored.
+// Float formats are clamped to 0 to 1 and integer formats to 255, formats without
+// blue or alpha read them as 0 and opaque. See ReadFloat32 for full precision.
+func (self *PingPong) ReadPixels() *image.RGBA {
+	if tex := self.Read(); tex.Format.Integer {
+		// integer color buffers can't be read as bytes
+		return integerImage(tex.ReadUint32(), tex.Format.Channels, self.Width, self.Height)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, self.Width, self.Height))
+
+	defer State.Push().Pop()
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, self.Framebuffer.Handle)
+	gl.FramebufferTexture2D(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.Read().Handle, 0)
+	gl.ReadPixels(0, 0, int32(self.Width), int32(self.Height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	return img
+}
+
+// integerImage integer texels as an image, channels are clamped to 255 and missing ones 0 and opaque
+func integerImage(values []uint32, channels, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		pix := img.Pix[i*4 : i*4+4]
+		pix[3] = 255
+		for c, v := range values[i*channels : (i+1)*channels] {
+			if v > 255 {
+				v = 255
+			}
+
+			pix[c] = uint8(v)
+		}
+	}
+
+	return img
+}
+
+// ReadFloat32 copy Read back at full precision, see Texture.ReadFloat32
+func (self *PingPong) ReadFloat32() []float32 {
+	return self.Read().ReadFloat32()
+}
+
+func (self *PingPong) Cleanup() {
+	State.DeleteTextures(self.textures[0].Handle, self.textures[1].Handle)
+	State.DeleteFramebuffers(self.Framebuffer.Handle)
 }
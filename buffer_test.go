@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"image"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"gogl/assets"
+	"gogl/headless"
+)
+
+// withGLContext make a headless context current for the rest of the test, skipping it
+// when there is none, e.g. when not built with -tags egl
+func withGLContext(t *testing.T) {
+	runtime.LockOSThread()
+	ctx, err := headless.NewContext()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Skip(err)
+	}
+
+	if err := gl.Init(); err != nil {
+		ctx.Destroy()
+		runtime.UnlockOSThread()
+		t.Skip(err)
+	}
+
+	State.Reset()
+	t.Cleanup(func() {
+		ctx.Destroy()
+		runtime.UnlockOSThread()
+	})
+}
+
+func TestIntegerImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []uint32
+		channels int
+		want     []uint8
+	}{
+		{"red", []uint32{7, 300}, 1, []uint8{7, 0, 0, 255, 255, 0, 0, 255}},
+		{"rgba", []uint32{1, 2, 3, 4, 256, 0, 9, 0}, 4, []uint8{1, 2, 3, 4, 255, 0, 9, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := integerImage(tt.values, tt.channels, 2, 1)
+			if !reflect.DeepEqual(img.Pix, tt.want) {
+				t.Errorf("pixels %v, want %v", img.Pix, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingPongRead(t *testing.T) {
+	withGLContext(t)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	copy(rgba.Pix, []uint8{10, 20, 30, 255, 40, 50, 60, 128})
+
+	tests := []struct {
+		name   string
+		format int32
+		data   interface{}
+		pixels []uint8
+		floats []float32
+	}{
+		{
+			name:   "rgba8",
+			format: gl.RGBA8,
+			data:   rgba,
+			pixels: rgba.Pix,
+			floats: []float32{10. / 255, 20. / 255, 30. / 255, 1, 40. / 255, 50. / 255, 60. / 255, 128. / 255},
+		},
+		{
+			name:   "rgba32f clamps pixels",
+			format: gl.RGBA32F,
+			data:   []float32{2.5, -1, 0.5, 1, 0, 1, 0, 0.25},
+			pixels: []uint8{255, 0, 128, 255, 0, 255, 0, 64},
+			floats: []float32{2.5, -1, 0.5, 1, 0, 1, 0, 0.25},
+		},
+		{
+			name:   "r32ui",
+			format: gl.R32UI,
+			data:   []uint32{7, 300},
+			pixels: []uint8{7, 0, 0, 255, 255, 0, 0, 255},
+			floats: []float32{7, 300},
+		},
+	}
+
+	// subtests run on other goroutines, without the context current
+	for _, tt := range tests {
+		p := NewPingPong(2, 1, tt.format)
+		p.Fill(tt.data)
+
+		if pixels := p.ReadPixels().Pix; !reflect.DeepEqual(pixels, tt.pixels) {
+			t.Errorf("%v: ReadPixels %v, want %v", tt.name, pixels, tt.pixels)
+		}
+
+		floats := p.ReadFloat32()
+		if len(floats) != len(tt.floats) {
+			t.Errorf("%v: ReadFloat32 %v, want %v", tt.name, floats, tt.floats)
+		} else {
+			for i := range floats {
+				if d := floats[i] - tt.floats[i]; d > 1e-6 || d < -1e-6 {
+					t.Errorf("%v: ReadFloat32 %v, want %v", tt.name, floats, tt.floats)
+					break
+				}
+			}
+		}
+
+		if code := gl.GetError(); code != gl.NO_ERROR {
+			t.Errorf("%v: gl error 0x%x", tt.name, code)
+		}
+
+		p.Cleanup()
+	}
+}
+
+// TestPingPongSwap each step reads the frame the last step wrote, a missing Swap keeps reading the first
+func TestPingPongSwap(t *testing.T) {
+	withGLContext(t)
+
+	quad := NewV4Buffer(QuadVertices, 2, 4)
+	shader, err := CompileShader(assets.VertexShader, `#version 410
+uniform sampler2D state;
+out vec4 outputColor;
+void main() {
+  outputColor = texelFetch(state, ivec2(gl_FragCoord.xy), 0) + 1.0;
+}`, quad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Cleanup()
+
+	p := NewPingPong(2, 1, gl.RGBA32F)
+	defer p.Cleanup()
+	p.Fill([]float32{0, 1, 2, 3, -4, 0.5, 10, 100})
+
+	for step := 0; step < 3; step++ {
+		p.Bind()
+		State.BindVertexArray(quad.VAO())
+		p.Read().Activate(gl.TEXTURE0)
+		shader.Use().Uniform1i("state", 0)
+		quad.Draw()
+		p.Swap()
+	}
+
+	want := []float32{3, 4, 5, 6, -1, 3.5, 13, 103}
+	if got := p.ReadFloat32(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after 3 steps %v, want %v", got, want)
+	}
+
+	if code := gl.GetError(); code != gl.NO_ERROR {
+		t.Errorf("gl error 0x%x", code)
+	}
+}
@@ -0,0 +1,154 @@
+package engine
+
+type ClockMode int
+
+const (
+	// ClockRealTime advances by the scaled wall time between frames
+	ClockRealTime ClockMode = iota
+	// ClockFixedStep advances by Scale/FPS each frame, frame N gets t = N/FPS
+	ClockFixedStep
+)
+
+// Clock drives the time passed to Program.Render
+type Clock struct {
+	Mode  ClockMode
+	FPS   float64
+	Scale float64
+
+	Paused bool
+	Frame  int
+
+	// wall clock seconds, used in real time mode
+	source func() float64
+
+	started bool
+	step    bool
+	last    float64
+
+	time, delta float64
+
+	// fixed step time is base + steps/FPS so it doesn't drift
+	base, steps float64
+}
+
+// NewClock create a real time clock reading seconds from source,
+// fps is the step size used when single stepping
+func NewClock(source func() float64, fps float64) *Clock {
+	return &Clock{
+		Mode:   ClockRealTime,
+		FPS:    fps,
+		Scale:  1.0,
+		Frame:  -1,
+		source: source,
+	}
+}
+
+// NewFixedClock create a clock that advances exactly 1/fps seconds per frame
+func NewFixedClock(fps float64) *Clock {
+	return &Clock{
+		Mode:  ClockFixedStep,
+		FPS:   fps,
+		Scale: 1.0,
+		Frame: -1,
+	}
+}
+
+// Tick advance to the next frame. Returns false when paused with no step pending.
+func (self *Clock) Tick() bool {
+	now := 0.0
+	if self.source != nil {
+		now = self.source()
+	}
+
+	if self.Paused && !self.step {
+		// hold time still while paused
+		self.last = now
+		self.delta = 0
+		return false
+	}
+
+	switch {
+	case !self.started:
+		self.delta = 0
+	case self.step || self.Mode == ClockFixedStep:
+		self.delta = self.Scale / self.FPS
+	default:
+		self.delta = (now - self.last) * self.Scale
+	}
+
+	if self.Mode == ClockFixedStep {
+		if self.started {
+			self.steps += self.Scale
+		}
+
+		self.time = self.base + self.steps/self.FPS
+	} else {
+		self.time += self.delta
+	}
+
+	self.started = true
+	self.step = false
+	self.last = now
+	self.Frame++
+	return true
+}
+
+// Time seconds elapsed on the clock at the current frame
+func (self *Clock) Time() float64 {
+	return self.time
+}
+
+// Delta seconds elapsed since the previous frame
+func (self *Clock) Delta() float64 {
+	return self.delta
+}
+
+func (self *Clock) SetMode(mode ClockMode) {
+	self.Mode = mode
+	self.base = self.time
+	self.steps = 0
+}
+
+func (self *Clock) SetScale(scale float64) {
+	// restart fixed step counting so past frames keep their times
+	self.base = self.time
+	self.steps = 0
+	self.Scale = scale
+}
+
+func (self *Clock) Pause() {
+	self.Paused = true
+}
+
+func (self *Clock) Resume() {
+	self.Paused = false
+}
+
+func (self *Clock) TogglePause() {
+	self.Paused = !self.Paused
+}
+
+// Step advance a single frame on the next tick while paused
+func (self *Clock) Step() {
+	self.step = true
+}
+
+// Rewind reset the clock to zero, the next frame is frame 0
+func (self *Clock) Rewind() {
+	self.started = false
+	self.time = 0
+	self.delta = 0
+	self.base = 0
+	self.steps = 0
+	self.Frame = -1
+}
+
+// Seek jump to a frame and time, the next tick renders it
+func (self *Clock) Seek(frame int, t float64) {
+	self.started = false
+	self.time = t
+	self.delta = 0
+	self.base = t
+	self.steps = 0
+	self.Frame = frame - 1
+}
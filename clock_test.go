@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClockTick(t *testing.T) {
+	tests := []struct {
+		name  string
+		clock func(source func() float64) *Clock
+		// wall clock seconds at each tick
+		wall  []float64
+		times []float64
+	}{
+		{
+			name:  "real time follows the wall clock",
+			clock: func(source func() float64) *Clock { return NewClock(source, 60) },
+			wall:  []float64{10, 10.5, 10.75, 12},
+			times: []float64{0, 0.5, 0.75, 2},
+		},
+		{
+			name:  "fixed step ignores the wall clock",
+			clock: func(source func() float64) *Clock { return NewFixedClock(4) },
+			wall:  []float64{10, 10.5, 30, 31},
+			times: []float64{0, 0.25, 0.5, 0.75},
+		},
+		{
+			name: "real time switched to fixed step",
+			clock: func(source func() float64) *Clock {
+				c := NewClock(source, 2)
+				c.SetMode(ClockFixedStep)
+				return c
+			},
+			wall:  []float64{0, 5, 7},
+			times: []float64{0, 0.5, 1},
+		},
+		{
+			name: "scaled real time",
+			clock: func(source func() float64) *Clock {
+				c := NewClock(source, 60)
+				c.SetScale(2)
+				return c
+			},
+			wall:  []float64{1, 2, 2.5},
+			times: []float64{0, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := 0
+			c := tt.clock(func() float64 { return tt.wall[i] })
+			for ; i < len(tt.wall); i++ {
+				if !c.Tick() {
+					t.Fatalf("tick %v: clock did not advance", i)
+				}
+
+				if c.Frame != i || math.Abs(c.Time()-tt.times[i]) > 1e-9 {
+					t.Errorf("tick %v: frame %v at %v, want frame %v at %v", i, c.Frame, c.Time(), i, tt.times[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClockSeek(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  ClockMode
+		wall  []float64
+		times []float64
+	}{
+		{"fixed step", ClockFixedStep, []float64{0, 100, 200}, []float64{3, 3.5, 4}},
+		{"real time", ClockRealTime, []float64{50, 50.25, 51}, []float64{3, 3.25, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := 0
+			c := NewClock(func() float64 { return tt.wall[i] }, 2)
+			c.SetMode(tt.mode)
+			c.Seek(6, 3)
+			for ; i < len(tt.wall); i++ {
+				c.Tick()
+				if c.Frame != 6+i || math.Abs(c.Time()-tt.times[i]) > 1e-9 {
+					t.Errorf("tick %v: frame %v at %v, want frame %v at %v", i, c.Frame, c.Time(), 6+i, tt.times[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClockPauseStep(t *testing.T) {
+	c := NewFixedClock(10)
+	c.Tick()
+	c.Pause()
+	if c.Tick() {
+		t.Fatal("paused clock advanced")
+	}
+
+	c.Step()
+	if !c.Tick() || math.Abs(c.Time()-0.1) > 1e-9 {
+		t.Errorf("step: at %v, want 0.1", c.Time())
+	}
+
+	if c.Tick() {
+		t.Error("clock advanced twice for one step")
+	}
+}
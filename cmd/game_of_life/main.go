@@ -3,8 +3,10 @@ package main
 import (
 	"image"
 	"image/color"
+	"log"
 	"math"
 	"math/rand"
+	"os"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -16,6 +18,9 @@ import (
 	_ "embed"
 )
 
+// SeedFile picture the simulation starts from instead of noise, set by the LIFE_SEED environment variable
+var SeedFile = os.Getenv("LIFE_SEED")
+
 func init() {
 	HotProgram = NewLifeProgram()
 }
@@ -43,7 +48,8 @@ const (
 )
 
 type LifeProgram struct {
-	Window        *glfw.Window
+	Window        Surface
+	Clock         *Clock
 	width, height int
 
 	// rule set
@@ -52,15 +58,13 @@ type LifeProgram struct {
 
 	// state
 	frame      int32
-	paused     bool
 	mode       LifeMode
 	cursorSize float64
 	cmds       CmdChannels
 
 	// textures
-	prevTexture        *Texture
-	nextTexture        *Texture
-	growthDecayTexture *Texture
+	state       *PingPong
+	growthDecay *PingPong
 
 	// compute shaders
 	lifeShader        Shader
@@ -72,8 +76,7 @@ type LifeProgram struct {
 	gradientIndex CyclicArray[int32]
 
 	// buffers
-	fbo uint32
-	bo  BufferObject
+	bo BufferObject
 }
 
 func NewLifeProgram() Program {
@@ -88,7 +91,6 @@ func NewLifeProgram() Program {
 		birth:   birth,
 
 		frame:      0,
-		paused:     false,
 		mode:       LifeStd,
 		cursorSize: 0.025,
 
@@ -98,17 +100,17 @@ func NewLifeProgram() Program {
 }
 
 func (self *LifeProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Clock = r.Clock
+	self.Load(r.Surface)
 }
 
-func (self *LifeProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *LifeProgram) Load(surface Surface) {
+	self.Window = surface
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	self.width, self.height = window.GetFramebufferSize()
+	self.width, self.height = surface.GetFramebufferSize()
 
 	// create textures
 	img1 := *image.NewRGBA(image.Rect(0, 0, self.width, self.height))
-	img2 := *image.NewRGBA(image.Rect(0, 0, self.width, self.height))
 	img3 := *image.NewRGBA(image.Rect(0, 0, self.width, self.height))
 	for x := 0; x < img1.Rect.Max.X; x++ {
 		for y := 0; y < img1.Rect.Max.Y; y++ {
@@ -119,15 +121,21 @@ func (self *LifeProgram) Load(window *glfw.Window) {
 
 			c := color.RGBA{r, g, b, a}
 			img1.Set(x, y, c)
-			img2.Set(x, y, c)
 			img3.Set(x, y, color.White)
 		}
 	}
 
 	// create compute textures
-	self.prevTexture = LoadTexture(&img1)
-	self.nextTexture = LoadTexture(&img2)
-	self.growthDecayTexture = LoadTexture(&img3)
+	self.state = NewPingPong(self.width, self.height, gl.RGBA8)
+	self.state.Rescale = true
+	self.state.Fill(&img1)
+	if SeedFile != "" {
+		self.seed(SeedFile)
+	}
+
+	self.growthDecay = NewPingPong(self.width, self.height, gl.RGBA8)
+	self.growthDecay.Rescale = true
+	self.growthDecay.Fill(&img3)
 
 	// create compute shaders
 	self.cyclicShader = MustCompileShader(VertexShader, CyclicShader, self.bo)
@@ -147,23 +155,31 @@ func (self *LifeProgram) Load(window *glfw.Window) {
 		MustCompileShader(VertexShader, RGBAShader, self.bo),
 	})
 
-	// create framebuffers
-	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
-
 	self.Window.SetScrollCallback(self.ScrollCallback)
 }
 
+// seed replace the state with a picture scaled to fit
+func (self *LifeProgram) seed(name string) {
+	seed, err := LoadTextureFile(name, TextureOptions{FlipY: true})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	self.state.Copy(seed)
+	State.DeleteTextures(seed.Handle)
+}
+
 func (self *LifeProgram) recolor() {
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 
 	switch self.mode {
 	case LifeStd:
-		self.growthDecayTexture.Activate(gl.TEXTURE0)
+		self.growthDecay.Read().Activate(gl.TEXTURE0)
 	case LifeCyclic:
-		self.prevTexture.Activate(gl.TEXTURE0)
+		self.state.Read().Activate(gl.TEXTURE0)
 	}
 
 	self.outputShaders.Current().Use().
@@ -179,11 +195,10 @@ func (self *LifeProgram) life(t float64) {
 
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 	// use gol program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.nextTexture.Handle, 0)
+	self.state.Bind()
 
-	gl.BindVertexArray(self.bo.VAO())
-	self.prevTexture.Activate(gl.TEXTURE0)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.lifeShader.Use().
 		Uniform1iv("s", self.survive).
@@ -197,15 +212,14 @@ func (self *LifeProgram) life(t float64) {
 	self.bo.Draw()
 
 	// swap texture
-	self.prevTexture, self.nextTexture = self.nextTexture, self.prevTexture
+	self.state.Swap()
 
 	// use decay program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.growthDecayTexture.Handle, 0)
+	self.growthDecay.Bind()
 
-	gl.BindVertexArray(self.bo.VAO())
-	self.prevTexture.Activate(gl.TEXTURE0)
-	self.growthDecayTexture.Activate(gl.TEXTURE1)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
+	self.growthDecay.Read().Activate(gl.TEXTURE1)
 
 	self.growthDecayShader.Use().
 		Uniform1i("state", 0).
@@ -215,11 +229,12 @@ func (self *LifeProgram) life(t float64) {
 		Uniform2f("u_mouse", float32(mx), float32(self.height)-float32(my)).
 		Uniform2f("u_resolution", float32(self.width), float32(self.height))
 	self.bo.Draw()
+	self.growthDecay.Swap()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
-	self.growthDecayTexture.Activate(gl.TEXTURE0)
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
+	self.growthDecay.Read().Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
 		Uniform1i("index", *self.gradientIndex.Current()).
@@ -236,11 +251,10 @@ func (self *LifeProgram) cyclic(t float64) {
 	mx, my := self.Window.GetCursorPos()
 
 	// use cyclic life program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.nextTexture.Handle, 0)
+	self.state.Bind()
 
-	gl.BindVertexArray(self.bo.VAO())
-	self.prevTexture.Activate(gl.TEXTURE0)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.cyclicShader.Use().
 		Uniform1f("stages", 16.0).
@@ -253,12 +267,12 @@ func (self *LifeProgram) cyclic(t float64) {
 	self.bo.Draw()
 
 	// swap texture
-	self.prevTexture, self.nextTexture = self.nextTexture, self.prevTexture
+	self.state.Swap()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
-	self.prevTexture.Activate(gl.TEXTURE0)
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
 		Uniform1i("index", *self.gradientIndex.Current()).
@@ -272,11 +286,6 @@ func (self *LifeProgram) Render(t float64) {
 	case <-self.cmds[RecolorCmd]:
 		self.recolor()
 	default:
-		if self.paused {
-			self.bo.Draw()
-			return
-		}
-
 		self.frame = self.frame + 1
 		switch self.mode {
 		case LifeStd:
@@ -287,6 +296,16 @@ func (self *LifeProgram) Render(t float64) {
 	}
 }
 
+func (self *LifeProgram) RenderPaused(t float64) bool {
+	select {
+	case <-self.cmds[RecolorCmd]:
+		self.recolor()
+		return true
+	default:
+		return false
+	}
+}
+
 func (self *LifeProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff float64) {
 	if yoff > 0 {
 		self.cursorSize = math.Max(0, self.cursorSize-0.005)
@@ -298,9 +317,8 @@ func (self *LifeProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff float
 func (self *LifeProgram) ResizeCallback(w *glfw.Window, width int, height int) {
 	self.width, self.height = self.Window.GetFramebufferSize()
 
-	self.prevTexture.Resize(self.width, self.height)
-	self.nextTexture.Resize(self.width, self.height)
-	self.growthDecayTexture.Resize(self.width, self.height)
+	self.state.Resize(self.width, self.height)
+	self.growthDecay.Resize(self.width, self.height)
 }
 
 func (self *LifeProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
@@ -334,7 +352,7 @@ func (self *LifeProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int,
 		}
 
 		if key == glfw.KeySpace {
-			self.paused = !self.paused
+			self.Clock.TogglePause()
 		}
 	}
 }
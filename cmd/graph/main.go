@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	. "gogl"
+)
+
+// GraphFile render graph to run, overridden by the RENDER_GRAPH environment variable
+var GraphFile = "./cmd/graph/life.json"
+
+func init() {
+	if name := os.Getenv("RENDER_GRAPH"); name != "" {
+		GraphFile = name
+	}
+
+	HotProgram = NewGraphProgram(GraphFile)
+}
+
+func HotProgramFn(kill <-chan bool, window *glfw.Window) {
+	HotRender(kill, window)
+}
+
+// GraphProgram runs a render graph described in a file, no go needed for a new simulation
+type GraphProgram struct {
+	file  string
+	graph *RenderGraph
+
+	*Renderer
+}
+
+func NewGraphProgram(file string) Program {
+	return &GraphProgram{file: file}
+}
+
+func (self *GraphProgram) Load(surface Surface) {}
+
+func (self *GraphProgram) LoadR(r *Renderer) {
+	self.Renderer = r
+
+	graph, err := LoadRenderGraph(self.file, r.Surface)
+	if err != nil {
+		panic(err)
+	}
+
+	self.graph = graph
+}
+
+func (self *GraphProgram) Render(t float64) {
+	self.graph.Render(t)
+}
+
+func (self *GraphProgram) ResizeCallback(w *glfw.Window, width int, height int) {
+	self.graph.Resize(width, height)
+}
+
+func (self *GraphProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeySpace && action == glfw.Release {
+		self.Clock.TogglePause()
+	}
+}
@@ -1,10 +1,42 @@
 package main
 
 import (
+	"flag"
+	"log"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	engine "gogl"
 	. "gogl/window"
 )
 
+var headless = flag.Bool("headless", false, "render offscreen without a window, requires -tags egl")
+var width = flag.Int("width", 1280, "headless framebuffer width")
+var height = flag.Int("height", 720, "headless framebuffer height")
+var frames = flag.Int("frames", 600, "headless frames to render")
+var record = flag.Bool("record", false, "headless record every frame")
+var posterWidth = flag.Int("poster-width", 0, "headless capture the last frame in tiles at this width")
+var posterHeight = flag.Int("poster-height", 0, "headless capture the last frame in tiles at this height")
+var hdr = flag.Bool("hdr", false, "headless render to a float framebuffer, the capture is saved as exr")
+var restore = flag.String("restore", "", "restore program state from a screenshot")
+
 func main() {
+	flag.Parse()
+	engine.RestoreFile = *restore
+
+	if *headless {
+		if *hdr {
+			engine.HeadlessInternalFormat = gl.RGBA32F
+		}
+
+		err := HeadlessRender("./bin/plugins/plug.so", *width, *height, *frames, *record, *posterWidth, *posterHeight)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
 	wnd := NewWindow()
 	defer wnd.Close()
 	wnd.HotWindow("./bin/plugins/", "plug.so")
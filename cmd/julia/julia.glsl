@@ -5,12 +5,13 @@ uniform vec2 offset;
 uniform float zoom;
 
 uniform vec2 scale;
+uniform vec2 tileOffset;
 
 in vec2 fragTexCoord;
 out vec4 outputColor;
 
 void main() {      
-  vec2 c = vec2(gl_FragCoord.xy);
+  vec2 c = gl_FragCoord.xy + tileOffset;
   /* c = (c * exp(-zoom)); */
 
   int iteration = 0;
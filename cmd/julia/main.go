@@ -25,7 +25,7 @@ func HotProgramFn(kill <-chan bool, window *glfw.Window) {
 var JuliaShader string
 
 type JuliaProgram struct {
-	Window *glfw.Window
+	Window Surface
 
 	// state
 	iterations int32
@@ -67,14 +67,14 @@ func NewJuliaProgram() Program {
 }
 
 func (self *JuliaProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Load(r.Surface)
 }
 
-func (self *JuliaProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *JuliaProgram) Load(surface Surface) {
+	self.Window = surface
 	self.mouseDelta = NewMouseDelta(self.Window, .0001)
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	width, height := window.GetFramebufferSize()
+	width, height := surface.GetFramebufferSize()
 
 	img := *image.NewRGBA(image.Rect(0, 0, width, height))
 
@@ -99,7 +99,7 @@ func (self *JuliaProgram) Load(window *glfw.Window) {
 
 	// create framebuffers
 	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 
 	self.Window.SetScrollCallback(self.ScrollCallback)
 	self.Window.SetCursorPosCallback(self.CursorPosCallback)
@@ -107,11 +107,20 @@ func (self *JuliaProgram) Load(window *glfw.Window) {
 
 func (self *JuliaProgram) Render(t float64) {
 	width, height := self.Window.GetFramebufferSize()
+	self.RenderTile(t, NewTile(width, height))
+}
+
+// RenderTile render part of a view larger than the screen
+func (self *JuliaProgram) RenderTile(t float64, tile Tile) {
+	width, height := tile.Width, tile.Height
+	if b := self.fractalTexture.Image.Bounds(); b.Dx() != width || b.Dy() != height {
+		self.fractalTexture.Resize(width, height)
+	}
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.fractalTexture.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindVertexArray(self.bo.VAO())
 	self.fractalTexture.Activate(gl.TEXTURE0)
 
 	self.fractalShader.Use().
@@ -119,12 +128,13 @@ func (self *JuliaProgram) Render(t float64) {
 		Uniform2f("focus", float32(self.cx), float32(self.cy)).
 		Uniform2f("offset", float32(self.ox), float32(self.oy)).
 		Uniform1f("zoom", float32(self.zoom)).
-		Uniform2f("scale", float32(width), float32(height))
+		Uniform2f("tileOffset", float32(tile.X), float32(tile.Y)).
+		Uniform2f("scale", float32(tile.FullWidth), float32(tile.FullHeight))
 	self.bo.Draw()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 	self.fractalTexture.Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
@@ -134,6 +144,41 @@ func (self *JuliaProgram) Render(t float64) {
 	self.bo.Draw()
 }
 
+// Params view state saved with screenshots
+func (self *JuliaProgram) Params() Params {
+	return Params{
+		"iterations":  self.iterations,
+		"zoom":        self.zoom,
+		"cx":          self.cx,
+		"cy":          self.cy,
+		"ox":          self.ox,
+		"oy":          self.oy,
+		"complexMode": self.complexMode,
+		"palette":     self.outputShaders.Index(),
+	}
+}
+
+func (self *JuliaProgram) SetParams(params Params) error {
+	palette := self.outputShaders.Index()
+	for _, err := range []error{
+		params.Int32("iterations", &self.iterations),
+		params.Float("zoom", &self.zoom),
+		params.Float("cx", &self.cx),
+		params.Float("cy", &self.cy),
+		params.Float("ox", &self.ox),
+		params.Float("oy", &self.oy),
+		params.Bool("complexMode", &self.complexMode),
+		params.Int("palette", &palette),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	self.outputShaders.OffsetIndex(palette - self.outputShaders.Index())
+	return nil
+}
+
 func (self *JuliaProgram) ZoomOut() {
 	self.zoom -= self.zoomFactor
 }
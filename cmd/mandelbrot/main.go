@@ -25,10 +25,10 @@ func HotProgramFn(kill <-chan bool, window *glfw.Window) {
 var MandelbrotShader string
 
 type MandelbrotProgram struct {
-	Window *glfw.Window
+	Window Surface
+	Clock  *Clock
 
 	// state
-	paused     bool
 	iterations int32
 	zoom       float64
 	x, y       float64
@@ -53,7 +53,6 @@ type MandelbrotProgram struct {
 
 func NewMandelbrotProgram() Program {
 	return &MandelbrotProgram{
-		paused:     false,
 		iterations: 1000,
 		x:          -0.51,
 		y:          0.0,
@@ -64,14 +63,15 @@ func NewMandelbrotProgram() Program {
 }
 
 func (self *MandelbrotProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Clock = r.Clock
+	self.Load(r.Surface)
 }
 
-func (self *MandelbrotProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *MandelbrotProgram) Load(surface Surface) {
+	self.Window = surface
 	self.mouseDelta = NewMouseDelta(self.Window, .0001)
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	width, height := window.GetFramebufferSize()
+	width, height := surface.GetFramebufferSize()
 
 	img := *image.NewRGBA(image.Rect(0, 0, width, height))
 
@@ -96,35 +96,41 @@ func (self *MandelbrotProgram) Load(window *glfw.Window) {
 
 	// create framebuffers
 	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 
 	self.Window.SetScrollCallback(self.ScrollCallback)
 	self.Window.SetCursorPosCallback(self.CursorPosCallback)
 }
 
 func (self *MandelbrotProgram) Render(t float64) {
-	if self.paused {
-		return
-	}
-
 	width, height := self.Window.GetFramebufferSize()
+	self.RenderTile(t, NewTile(width, height))
+}
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+// RenderTile render part of a view larger than the screen
+func (self *MandelbrotProgram) RenderTile(t float64, tile Tile) {
+	width, height := tile.Width, tile.Height
+	if b := self.fractalTexture.Image.Bounds(); b.Dx() != width || b.Dy() != height {
+		self.fractalTexture.Resize(width, height)
+	}
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.fractalTexture.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindVertexArray(self.bo.VAO())
 	self.fractalTexture.Activate(gl.TEXTURE0)
 
 	self.fractalShader.Use().
 		Uniform1i("maxIterations", self.iterations).
 		Uniform2f("focus", float32(self.x), float32(self.y)).
 		Uniform1f("zoom", float32(self.zoom)).
-		Uniform2f("scale", float32(width), float32(height))
+		Uniform2f("tileOffset", float32(tile.X), float32(tile.Y)).
+		Uniform2f("scale", float32(tile.FullWidth), float32(tile.FullHeight))
 	self.bo.Draw()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 	self.fractalTexture.Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
@@ -134,6 +140,35 @@ func (self *MandelbrotProgram) Render(t float64) {
 	self.bo.Draw()
 }
 
+// Params view state saved with screenshots
+func (self *MandelbrotProgram) Params() Params {
+	return Params{
+		"iterations": self.iterations,
+		"zoom":       self.zoom,
+		"x":          self.x,
+		"y":          self.y,
+		"palette":    self.outputShaders.Index(),
+	}
+}
+
+func (self *MandelbrotProgram) SetParams(params Params) error {
+	palette := self.outputShaders.Index()
+	for _, err := range []error{
+		params.Int32("iterations", &self.iterations),
+		params.Float("zoom", &self.zoom),
+		params.Float("x", &self.x),
+		params.Float("y", &self.y),
+		params.Int("palette", &palette),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	self.outputShaders.OffsetIndex(palette - self.outputShaders.Index())
+	return nil
+}
+
 func (self *MandelbrotProgram) ZoomOut() {
 	self.zoom -= self.zoomFactor
 }
@@ -164,7 +199,7 @@ func (self *MandelbrotProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff
 
 func (self *MandelbrotProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	if key == glfw.KeySpace && action == glfw.Release {
-		self.paused = !self.paused
+		self.Clock.TogglePause()
 	}
 
 	if key == glfw.KeyEqual {
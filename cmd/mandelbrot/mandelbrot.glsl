@@ -4,13 +4,14 @@ uniform vec2 focus;
 uniform float zoom;
 
 uniform vec2 scale;
+uniform vec2 tileOffset;
 
 in vec2 fragTexCoord;
 out vec4 outputColor;
 
 void main() {      
   // C is the aspect-ratio corrected UV coordinate.
-  vec2 c = (-1.0 + 2.0 * gl_FragCoord.xy / scale.xy) * vec2(scale.x / scale.y, 1.0);
+  vec2 c = (-1.0 + 2.0 * (gl_FragCoord.xy + tileOffset) / scale.xy) * vec2(scale.x / scale.y, 1.0);
   c = (c * exp(-zoom)) + focus;
 
 
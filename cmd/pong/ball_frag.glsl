@@ -0,0 +1,13 @@
+// pong balls
+#version 410
+in vec2 corner;
+
+out vec4 outputColor;
+
+void main() {
+  if (length(corner) >= 1.0) {
+    discard;
+  }
+
+  outputColor = vec4(1.0);
+}
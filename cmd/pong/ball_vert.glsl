@@ -0,0 +1,14 @@
+// pong balls, one instance per ball
+#version 410
+uniform vec2 iResolution;
+
+layout(location = 0) in vec2 vert;
+layout(location = 2) in vec3 ball; // position and size in pixels
+
+out vec2 corner;
+
+void main() {
+  corner = vert;
+  vec2 c = ball.xy + vert * ball.z;
+  gl_Position = vec4(c / iResolution * 2.0 - 1.0, 0, 1);
+}
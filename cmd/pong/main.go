@@ -3,6 +3,7 @@ package main
 import (
 	"image"
 	"image/color"
+	"log"
 	"math"
 	"math/rand"
 
@@ -31,6 +32,15 @@ var RecolorCmd = "recolor"
 //go:embed pong.glsl
 var PongShader string
 
+//go:embed ball_vert.glsl
+var BallVertexShader string
+
+//go:embed ball_frag.glsl
+var BallShader string
+
+// BallLayout x, y and size of each ball, read once per instance of the ball quad
+var BallLayout = VertexLayout{{Location: 2, Components: 3, Divisor: 1}}
+
 type Pong struct {
 	Heading  mgl64.Vec2
 	Position mgl64.Vec2
@@ -85,29 +95,33 @@ func (self *Pong) Advance() {
 }
 
 type PongProgram struct {
-	Window *glfw.Window
+	Window Surface
+	Clock  *Clock
 
 	pong []*Pong
 
 	// state
-	frame  int32
-	paused bool
-	alpha  float64
-	cmds   CmdChannels
+	frame int32
+	alpha float64
+	cmds  CmdChannels
 
 	// textures
 	tex *Texture
 
 	// compute shaders
 	pongShader Shader
+	ballShader Shader
 
 	// output shaders
 	outputShaders CyclicArray[Shader]
 	gradientIndex CyclicArray[int32]
 
 	// buffers
-	fbo uint32
-	bo  BufferObject
+	fbo   uint32
+	bo    BufferObject
+	quad  BufferObject
+	balls *StreamBuffer
+	data  Vertices
 }
 
 func NewPongProgram() Program {
@@ -122,21 +136,33 @@ func NewPongProgram() Program {
 	return &PongProgram{
 		pong: pongs,
 
-		frame:  0,
-		paused: false,
-		alpha:  0.0,
+		frame: 0,
+		alpha: 0.0,
 
 		cmds:          cmds,
 		gradientIndex: *NewCyclicArray([]int32{0, 1, 2, 3}),
 	}
 }
 func (self *PongProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Clock = r.Clock
+	self.Load(r.Surface)
 }
-func (self *PongProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *PongProgram) Load(surface Surface) {
+	self.Window = surface
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	width, height := window.GetFramebufferSize()
+
+	// every ball is an instance of a quad, positions are streamed in one write a frame
+	self.quad = NewV4Buffer(QuadVertices, 2, 4)
+	balls, err := NewStreamBuffer(BallLayout, len(self.pong)*3*F32_SIZE, gl.POINTS)
+	if err != nil {
+		panic(err)
+	}
+
+	self.balls = balls
+	self.balls.Attach(self.quad)
+	self.data = make(Vertices, 0, len(self.pong)*3)
+
+	width, height := surface.GetFramebufferSize()
 
 	// create textures
 	prev := *image.NewRGBA(image.Rect(0, 0, width, height))
@@ -159,6 +185,7 @@ func (self *PongProgram) Load(window *glfw.Window) {
 
 	// create compute shaders
 	self.pongShader = MustCompileShader(VertexShader, PongShader, self.bo)
+	self.ballShader = MustCompileShader(BallVertexShader, BallShader, self.quad)
 
 	// create output shaders
 	self.outputShaders = *NewCyclicArray([]Shader{
@@ -175,7 +202,7 @@ func (self *PongProgram) Load(window *glfw.Window) {
 
 	// create framebuffers
 	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 
 	self.Window.SetScrollCallback(self.ScrollCallback)
 
@@ -188,8 +215,8 @@ func (self *PongProgram) recolor() {
 	width, height := self.Window.GetFramebufferSize()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 
 	self.tex.Activate(gl.TEXTURE0)
 	self.outputShaders.Current().Use().
@@ -201,39 +228,35 @@ func (self *PongProgram) recolor() {
 
 func (self *PongProgram) run(t float64) {
 	width, height := self.Window.GetFramebufferSize()
-	mx, my := self.Window.GetCursorPos()
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.tex.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindVertexArray(self.bo.VAO())
 	self.tex.Activate(gl.TEXTURE0)
 
+	self.pongShader.Use().
+		Uniform1i("iChannel1", 0).
+		Uniform2f("iResolution", float32(width), float32(height))
+	self.bo.Draw()
+
+	self.data = self.data[:0]
 	for _, p := range self.pong {
 		p.Advance()
+		self.data = append(self.data, float32(p.Position[0]), float32(p.Position[1]), float32(p.Size))
 	}
 
-	sizes := make([]float32, 0)
-	pongs := make([]float32, 0)
-	for _, p := range self.pong {
-		sizes = append(sizes, float32(p.Size))
-		pongs = append(pongs, float32(p.Position[0]))
-		pongs = append(pongs, float32(p.Position[1]))
+	if err := self.balls.Write(self.data); err != nil {
+		log.Println(err)
+	} else {
+		self.ballShader.Use().
+			Uniform2f("iResolution", float32(width), float32(height))
+		self.quad.DrawRange(DrawRange{Count: 4, Instances: int32(self.balls.Count)})
 	}
 
-	self.pongShader.Use().
-		Uniform2fv("pPos", pongs).
-		Uniform1fv("pSize", sizes).
-		Uniform1i("len", int32(len(self.pong))).
-		Uniform1i("iChannel1", 0).
-		Uniform1f("iTime", float32(t)).
-		Uniform2f("iResolution", float32(width), float32(height)).
-		Uniform2f("iMouse", float32(mx), float32(height)-float32(my))
-	self.bo.Draw()
-
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 	self.tex.Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
@@ -249,16 +272,21 @@ func (self *PongProgram) Render(t float64) {
 	case <-self.cmds[RecolorCmd]:
 		self.recolor()
 	default:
-		if self.paused {
-			self.bo.Draw()
-			return
-		}
-
 		self.frame = self.frame + 1
 		self.run(t)
 	}
 }
 
+func (self *PongProgram) RenderPaused(t float64) bool {
+	select {
+	case <-self.cmds[RecolorCmd]:
+		self.recolor()
+		return true
+	default:
+		return false
+	}
+}
+
 func (self *PongProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff float64) {
 	if yoff > 0 {
 		self.alpha = math.Max(0, self.alpha-0.005)
@@ -298,7 +326,7 @@ func (self *PongProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int,
 		}
 
 		if key == glfw.KeySpace {
-			self.paused = !self.paused
+			self.Clock.TogglePause()
 		}
 	}
 }
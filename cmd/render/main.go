@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"plugin"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	. "gogl"
+	"gogl/headless"
+)
+
+// Render a program offline, frame by frame at a fixed rate.
+// Frames take as long as they take, output always plays back smoothly.
+
+var pluginFile = flag.String("plugin", "./bin/plugins/plug.so", "program plugin to render")
+var width = flag.Int("width", 1920, "output width")
+var height = flag.Int("height", 1080, "output height")
+var frames = flag.Int("frames", 600, "frames to render")
+var fps = flag.Float64("fps", 60, "frames per second of output")
+var format = flag.String("format", "png", "comma separated output formats: png, avi, apng, y4m or both (png,avi)")
+var restore = flag.String("restore", "", "restore program state from a screenshot")
+var out = flag.String("out", "", "output folder (default screencaptures/renders/<timestamp>)")
+
+func init() {
+	runtime.LockOSThread()
+}
+
+func main() {
+	flag.Parse()
+	RestoreFile = *restore
+
+	// opening the plugin runs its init, setting HotProgram
+	if _, err := plugin.Open(*pluginFile); err != nil {
+		log.Fatalln(err)
+	}
+
+	folder := *out
+	if folder == "" {
+		folder = filepath.Join("screencaptures", "renders", time.Now().Format("20060102150405"))
+	}
+
+	encoders := make([]FrameEncoder, 0)
+	for _, f := range strings.Split(strings.ReplaceAll(*format, "both", "png,avi"), ",") {
+		var enc FrameEncoder
+		var err error
+		switch f {
+		case "png":
+			enc, err = NewPNGSequenceEncoder(filepath.Join(folder, "frames"))
+		case "avi":
+			enc, err = NewMJPEGEncoder(filepath.Join(folder, "render.avi"), *width, *height, *fps)
+		case "apng":
+			enc, err = NewAPNGEncoder(filepath.Join(folder, "render.png"), *width, *height)
+		case "y4m":
+			enc, err = NewY4MEncoder(filepath.Join(folder, "render.y4m"), *width, *height, *fps)
+		default:
+			log.Fatalln("unknown format:", f)
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		encoders = append(encoders, enc)
+	}
+
+	if len(encoders) == 0 {
+		log.Fatalln("unknown format:", *format)
+	}
+
+	destroy, err := makeContext()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer destroy()
+
+	fmt.Printf("Rendering %v frames @ %v x %v, %v fps\n", *frames, *width, *height, *fps)
+	if err := OfflineRender(*width, *height, *frames, *fps, encoders...); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// makeContext prefer a surfaceless context, fall back to a hidden window
+func makeContext() (func(), error) {
+	ctx, err := headless.NewContext()
+	if err == nil {
+		return ctx.Destroy, nil
+	}
+
+	if err := glfw.Init(); err != nil {
+		return nil, err
+	}
+
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	glfw.WindowHint(glfw.Visible, glfw.False)
+
+	window, err := glfw.CreateWindow(64, 64, "render", nil, nil)
+	if err != nil {
+		glfw.Terminate()
+		return nil, err
+	}
+
+	window.MakeContextCurrent()
+	return glfw.Terminate, nil
+}
@@ -25,12 +25,10 @@ func HotProgramFn(kill <-chan bool, window *glfw.Window) {
 }
 
 type LiveEditProgram struct {
-	paused           bool
-	frame            int
-	deltaTime        float64
-	currentFrameTime float64
+	frame int
 
 	watcher *ShaderWatcher
+	overlay *ShaderErrorOverlay
 
 	// current shader
 	shader       *Shader
@@ -44,7 +42,7 @@ type LiveEditProgram struct {
 	// buffers
 	quad        BufferObject
 	bo          BufferObject
-	rbo         *Renderbuffer
+	target      *RenderTarget
 	lightSource BufferObject
 	light       *DirectionalLight
 
@@ -70,20 +68,31 @@ func NewLiveEditProgram(vert, frag string) Program {
 	}
 }
 
-func (self *LiveEditProgram) Load(window *glfw.Window) {}
+func (self *LiveEditProgram) Load(surface Surface) {}
 
 func (self *LiveEditProgram) LoadR(r *Renderer) {
 	// setup window
 	self.Renderer = r
 
 	// setup input
-	self.MouseDelta = NewMouseDelta(self.Window, 0.1)
-
-	// create renderbuffer for post processing
-	self.rbo = NewRenderbuffer(self.Width, self.Height)
+	self.MouseDelta = NewMouseDelta(self.Surface, 0.1)
+
+	// create multisampled color, normal and depth targets for post processing
+	target, err := NewFramebufferBuilder(self.Width, self.Height).
+		Color(gl.RGBA8, LinearTextureOptions).
+		Color(gl.RGBA8, LinearTextureOptions).
+		Depth(gl.DEPTH24_STENCIL8).
+		Samples(4).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	self.target = target
 
-	// create watcher
-	self.watcher = NewShaderWatcher()
+	// create watcher, errors are drawn over the last good frame until fixed
+	self.watcher = NewShaderWatcher(DefaultShaderDebounce)
+	self.overlay = NewShaderErrorOverlay()
+	self.watcher.OnDiagnostics = self.overlay.Update
 
 	// create scene shader+vao (all cubes)
 	self.bo = NewVIBuffer(CubeAltVertices, CubeAltIndices, 36)
@@ -117,7 +126,7 @@ func (self *LiveEditProgram) LoadR(r *Renderer) {
 	gl.FrontFace(gl.CCW)
 
 	// setup callbacks
-	self.Window.SetCursorPosCallback(self.CursorPosCallback)
+	self.Surface.SetCursorPosCallback(self.CursorPosCallback)
 
 	// setup camera
 	self.Camera = NewCamera(self.Width, self.Height)
@@ -158,20 +167,20 @@ func (self *LiveEditProgram) LoadR(r *Renderer) {
 func (self *LiveEditProgram) ShaderAppliactor(s Shader) Shader {
 	return s.
 		Uniform1i("u_frame", int32(self.frame)).
-		Uniform1f("u_time", float32(self.currentFrameTime)).
-		Uniform1f("u_delta", float32(self.deltaTime)).
+		Uniform1f("u_time", float32(self.Clock.Time())).
+		Uniform1f("u_delta", float32(self.Clock.Delta())).
 		Uniform2f("u_mouse", float32(self.mx), float32(self.Height)-float32(self.my)).
 		Uniform2f("u_resolution", float32(self.Width), float32(self.Height))
 }
 
 func (self *LiveEditProgram) run(t float64) {
-	self.mx, self.my = self.Window.GetCursorPos()
+	self.mx, self.my = self.Surface.GetCursorPos()
 
 	// first pass to fbo
 	if !self.postDisabled {
-		self.rbo.Bind()
+		self.target.Bind()
 	} else {
-		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
 	}
 
 	gl.Enable(gl.DEPTH_TEST)
@@ -187,8 +196,6 @@ func (self *LiveEditProgram) run(t float64) {
 	// todo frustum culling
 	// walk scene
 	i := 0
-	bufs := []uint32{uint32(gl.COLOR_ATTACHMENT0), uint32(gl.COLOR_ATTACHMENT1)}
-	gl.DrawBuffers(2, &bufs[0])
 	self.Scene.Root.Walk(func(mm mgl32.Mat4, n *Transform) {
 		cubeRotation := 360.0 * math.Sin(t/self.muls[i]) / 2.0
 		cubeAngle := float32(Deg2Rad(cubeRotation))
@@ -219,13 +226,12 @@ func (self *LiveEditProgram) run(t float64) {
 	self.lightSource.Draw()
 
 	// second pass
-	// TODO: handle rbo with multiple textures
-	//  handle getting and activating textures
 	if !self.postDisabled {
-		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		self.target.Resolve()
+		State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
 		gl.Clear(gl.COLOR_BUFFER_BIT)
-		self.rbo.Texture0.Activate(gl.TEXTURE0)
-		self.rbo.Texture1.Activate(gl.TEXTURE1)
+		self.target.Colors[0].Activate(gl.TEXTURE0)
+		self.target.Colors[1].Activate(gl.TEXTURE1)
 		self.post.Use().
 			Uniform1i("color_buffer", 0).
 			Uniform1i("normal_buffer", 1).
@@ -235,49 +241,59 @@ func (self *LiveEditProgram) run(t float64) {
 		gl.Disable(gl.DEPTH_TEST)
 		self.quad.Draw()
 	}
+
+	self.overlay.Draw(self.Width, self.Height)
 }
 
-func (self *LiveEditProgram) Render(t float64) {
-	select {
-	case event, ok := <-self.watcher.Events:
-		shader, err := self.watcher.Handle(event, ok)
-		if err != nil {
-			msg := err.Error()
-			log.Println(msg)
-			beeep.Notify("Shader Compilation Error", "", "")
-		} else if shader != nil {
-			gl.BindFragDataLocation(*shader.Program, 0, gl.Str("position\x00"))
+func (self *LiveEditProgram) handle(files []string, ok bool) {
+	shaders, err := self.watcher.Handle(files, ok)
+	for _, shader := range shaders {
+		gl.BindFragDataLocation(*shader.Program, 0, gl.Str("position\x00"))
+	}
 
-			if self.paused {
-				self.TogglePause()
-			}
-		}
-	default:
-		if self.paused {
-			return
+	if err != nil {
+		// compile diagnostics are logged by the watcher, show the first
+		msg := err.Error()
+		if len(self.watcher.Diagnostics) > 0 {
+			msg = self.watcher.Diagnostics[0].String()
+		} else {
+			log.Println(msg)
 		}
 
-		currentFrameTime := glfw.GetTime()
-		self.deltaTime = currentFrameTime - self.currentFrameTime
-		self.currentFrameTime = currentFrameTime
+		beeep.Notify("Shader Compilation Error", msg, "")
+	} else if len(shaders) > 0 {
+		// resume on successful edit
+		self.Clock.Resume()
+	}
+}
 
+func (self *LiveEditProgram) Render(t float64) {
+	select {
+	case files, ok := <-self.watcher.Changes:
+		self.handle(files, ok)
+	default:
 		self.frame = self.frame + 1
 		self.ProcessInput()
 		self.run(t)
 	}
 }
 
-func (self *LiveEditProgram) TogglePause() {
-	self.paused = !self.paused
-	self.PauseBufferSwap = self.paused
-	if !self.paused {
-		glfw.SetTime(self.currentFrameTime)
+func (self *LiveEditProgram) RenderPaused(t float64) bool {
+	select {
+	case files, ok := <-self.watcher.Changes:
+		// redraw the paused frame so the overlay is shown or cleared
+		self.handle(files, ok)
+		self.run(t)
+		return true
+	default:
 	}
+
+	return false
 }
 
 func (self *LiveEditProgram) CursorPosCallback(w *glfw.Window, x, y float64) {
 	dx, dy := self.Delta(x, y)
-	if self.Window.GetInputMode(glfw.CursorMode) == glfw.CursorDisabled {
+	if self.Surface.GetInputMode(glfw.CursorMode) == glfw.CursorDisabled {
 		// pan screen
 		self.Camera.Yaw += dx
 		self.Camera.Pitch += dy
@@ -304,7 +320,7 @@ func (self *LiveEditProgram) ProcessInput() {
 		mul = 2.0
 	}
 
-	cameraSpeed := float32(32.0 * self.deltaTime * mul)
+	cameraSpeed := float32(32.0 * self.Clock.Delta() * mul)
 
 	if self.KeyPressDetection.Down[glfw.KeyW] {
 		self.Camera.Position = self.Camera.Position.Add(self.Camera.Front.Mul(cameraSpeed))
@@ -338,7 +354,7 @@ func (self *LiveEditProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode
 	self.KeyPressDetection.HandleKeyPress(key, action, mods)
 	if key == glfw.KeySpace && action == glfw.Release {
 		if mods == glfw.ModControl {
-			self.TogglePause()
+			self.Clock.TogglePause()
 		} else {
 			self.postDisabled = !self.postDisabled
 		}
@@ -355,5 +371,7 @@ func (self *LiveEditProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode
 
 func (self *LiveEditProgram) ResizeCallback(w *glfw.Window, width int, height int) {
 	self.Camera.Resize(width, height)
-	self.rbo.Resize(width, height)
+	if err := self.target.Resize(width, height); err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,11 @@
+// fading trails of an orbiting point, or the mouse while pressed
+void mainImage(out vec4 fragColor, in vec2 fragCoord) {
+    vec2 uv = fragCoord / iResolution.xy;
+    vec4 previous = iFrame == 0 ? vec4(0.0) : texture(iChannel0, uv);
+
+    vec2 p = iMouse.z > 0.0 ? iMouse.xy : orbit(iTime, iResolution.xy);
+    float d = length(fragCoord - p);
+    float dot = smoothstep(12.0, 8.0, d);
+
+    fragColor = vec4(max(previous.rgb * 0.985, vec3(dot)), 1.0);
+}
@@ -0,0 +1,4 @@
+// shared by every pass
+vec2 orbit(float t, vec2 resolution) {
+    return resolution * (0.5 + 0.35 * vec2(cos(t), sin(t * 1.3)));
+}
@@ -0,0 +1,6 @@
+// color the trails by age
+void mainImage(out vec4 fragColor, in vec2 fragCoord) {
+    float v = texture(iChannel0, fragCoord / iResolution.xy).r;
+    vec3 col = 0.5 + 0.5 * cos(6.2831 * (v * 0.8 + vec3(0.0, 0.33, 0.67)) + iTime);
+    fragColor = vec4(col * v, 1.0);
+}
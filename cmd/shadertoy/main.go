@@ -0,0 +1,584 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	. "gogl"
+	. "gogl/assets"
+)
+
+// ManifestFile shadertoy to run, overridden by the SHADERTOY environment variable
+var ManifestFile = "./cmd/shadertoy/shadertoy.json"
+
+func init() {
+	if name := os.Getenv("SHADERTOY"); name != "" {
+		ManifestFile = name
+	}
+
+	HotProgram = NewShadertoyProgram(ManifestFile)
+}
+
+func HotProgramFn(kill <-chan bool, window *glfw.Window) {
+	HotRender(kill, window)
+}
+
+// Manifest passes of a shadertoy, paths are relative to the manifest
+type Manifest struct {
+	// Common source included before every pass
+	Common string `json:"common"`
+	// Buffers A to D, rendered in that order before the image
+	Buffers map[string]*Pass `json:"buffers"`
+	Image   *Pass            `json:"image"`
+}
+
+type Pass struct {
+	// Source defines mainImage(out vec4 fragColor, in vec2 fragCoord)
+	Source   string      `json:"source"`
+	Channels [4]*Channel `json:"channels"`
+}
+
+// Channel input bound to iChannel0..3
+type Channel struct {
+	// Buffer A to D, reading itself or a buffer rendered later gives its previous frame
+	Buffer string `json:"buffer"`
+	// Texture png or jpeg file
+	Texture string `json:"texture"`
+
+	// Filter nearest, linear or mipmap, mipmap only applies to textures. Default linear.
+	Filter string `json:"filter"`
+	// Wrap clamp or repeat. Default clamp.
+	Wrap string `json:"wrap"`
+	// VFlip textures so their top row is at uv.y = 1, as shadertoy does. Default true.
+	VFlip *bool `json:"vflip"`
+}
+
+var bufferNames = []string{"A", "B", "C", "D"}
+
+var shadertoyUniforms = []string{
+	"iResolution", "iTime", "iTimeDelta", "iFrameRate", "iFrame",
+	"iChannelTime", "iChannelResolution", "iMouse", "iDate", "iSampleRate",
+	"iChannel0", "iChannel1", "iChannel2", "iChannel3",
+}
+
+// LoadManifest read a manifest, resolving its paths
+func LoadManifest(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	if manifest.Image == nil {
+		return nil, fmt.Errorf("%v: no image pass", name)
+	}
+
+	dir := filepath.Dir(name)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(dir, path)
+	}
+
+	manifest.Common = resolve(manifest.Common)
+	for buffer, pass := range manifest.Buffers {
+		if !isBufferName(buffer) {
+			return nil, fmt.Errorf("%v: unknown buffer %q, expected A to D", name, buffer)
+		}
+
+		if pass == nil {
+			delete(manifest.Buffers, buffer)
+		}
+	}
+
+	for _, pass := range manifest.passes() {
+		pass.Source = resolve(pass.Source)
+		for i, c := range pass.Channels {
+			if c == nil {
+				continue
+			}
+
+			c.Texture = resolve(c.Texture)
+			if c.Buffer != "" && manifest.Buffers[c.Buffer] == nil {
+				return nil, fmt.Errorf("%v: iChannel%v reads buffer %q which has no pass", name, i, c.Buffer)
+			}
+		}
+	}
+
+	return manifest, nil
+}
+
+// passes buffers in order then the image
+func (self *Manifest) passes() []*Pass {
+	passes := make([]*Pass, 0, 5)
+	for _, name := range bufferNames {
+		if pass := self.Buffers[name]; pass != nil {
+			passes = append(passes, pass)
+		}
+	}
+
+	return append(passes, self.Image)
+}
+
+func isBufferName(name string) bool {
+	for _, b := range bufferNames {
+		if b == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shadertoyPass a compiled pass, buffers render to float textures and swap after each frame
+type shadertoyPass struct {
+	name     string
+	shader   Shader
+	channels [4]*Channel
+
+	// target float textures, Read holds the last frame, nil for the image pass
+	target *PingPong
+}
+
+type ShadertoyProgram struct {
+	manifestFile string
+
+	frame  int32
+	passes []*shadertoyPass
+	// buffer passes by name
+	buffers  map[string]*shadertoyPass
+	textures map[string]*Texture
+
+	// files the passes were built from, any change reloads them
+	dependencies map[string]bool
+	watched      map[string]bool
+	watcher      *ShaderWatcher
+	overlay      *ShaderErrorOverlay
+
+	mouse     [4]float32
+	mouseDown bool
+
+	quad BufferObject
+	*Renderer
+}
+
+func NewShadertoyProgram(manifest string) Program {
+	return &ShadertoyProgram{
+		manifestFile: manifest,
+
+		buffers:      make(map[string]*shadertoyPass),
+		textures:     make(map[string]*Texture),
+		dependencies: make(map[string]bool),
+		watched:      make(map[string]bool),
+	}
+}
+
+func (self *ShadertoyProgram) Load(surface Surface) {}
+
+func (self *ShadertoyProgram) LoadR(r *Renderer) {
+	self.Renderer = r
+	self.quad = NewV4Buffer(QuadVertices, 2, 4)
+
+	// errors are drawn over the last good frame until fixed
+	self.watcher = NewShaderWatcher(DefaultShaderDebounce)
+	self.overlay = NewShaderErrorOverlay()
+
+	if err := self.reload(); err != nil {
+		log.Println(err)
+	}
+}
+
+// reload build every pass from the manifest, on error the previous passes are kept
+func (self *ShadertoyProgram) reload() error {
+	dependencies := map[string]bool{AbsPath(self.manifestFile): true}
+	defer self.watch(dependencies)
+
+	manifest, err := LoadManifest(self.manifestFile)
+	if err != nil {
+		self.overlay.Update(ShaderErrorDiagnostics(err))
+		return err
+	}
+
+	passes := make([]*shadertoyPass, 0)
+	textures := make(map[string]*Texture)
+	cleanup := func() {
+		for _, p := range passes {
+			p.Cleanup()
+		}
+
+		deleteTextures(textures)
+	}
+
+	for _, pass := range manifest.passes() {
+		name := "Image"
+		for _, b := range bufferNames {
+			if manifest.Buffers[b] == pass {
+				name = b
+			}
+		}
+
+		p, deps, err := self.compile(name, manifest.Common, pass)
+		for _, dep := range deps {
+			if dep != "" {
+				dependencies[AbsPath(dep)] = true
+			}
+		}
+
+		if err != nil {
+			cleanup()
+			self.overlay.Update(ShaderErrorDiagnostics(err))
+			return err
+		}
+
+		passes = append(passes, p)
+		for i, c := range pass.Channels {
+			if c == nil || c.Texture == "" {
+				continue
+			}
+
+			dependencies[AbsPath(c.Texture)] = true
+			if _, err = loadTexture(textures, c); err != nil {
+				cleanup()
+				err = fmt.Errorf("%v iChannel%v: %v", name, i, err)
+				self.overlay.Update(ShaderErrorDiagnostics(err))
+				return err
+			}
+		}
+	}
+
+	for _, p := range self.passes {
+		p.Cleanup()
+	}
+
+	deleteTextures(self.textures)
+	self.textures = textures
+	self.passes = passes
+	self.buffers = make(map[string]*shadertoyPass)
+	for _, p := range passes {
+		if p.name != "Image" {
+			p.target = NewPingPong(self.Width, self.Height, gl.RGBA32F)
+			self.buffers[p.name] = p
+		}
+	}
+
+	// simulations start over from frame 0
+	self.frame = 0
+	self.overlay.Update(nil)
+	return nil
+}
+
+// compile wrap a pass's source with the shadertoy inputs and entry point.
+// The pass is the root source so drivers that ignore #line source numbers still name it.
+func (self *ShadertoyProgram) compile(name, common string, pass *Pass) (*shadertoyPass, []string, error) {
+	deps := []string{pass.Source}
+	if common != "" {
+		deps = append(deps, common)
+	}
+
+	data, err := os.ReadFile(pass.Source)
+	if err != nil {
+		return nil, deps, err
+	}
+
+	var source strings.Builder
+	source.WriteString("#version 410\n")
+	if name == "Image" {
+		source.WriteString("#define SHADERTOY_IMAGE\n")
+	}
+
+	source.WriteString("#include \"shadertoy/header.glsl\"\n")
+	if common != "" {
+		fmt.Fprintf(&source, "#include \"%v\"\n", filepath.ToSlash(AbsPath(common)))
+	}
+
+	source.WriteString("#line 1 0\n")
+	source.Write(data)
+	source.WriteString("\n#include \"shadertoy/footer.glsl\"\n")
+
+	vert, err := PreprocessShader("", VertexShader)
+	if err != nil {
+		return nil, deps, err
+	}
+
+	frag, err := PreprocessShader(pass.Source, source.String())
+	if err != nil {
+		return nil, deps, err
+	}
+
+	p := &shadertoyPass{name: name, channels: pass.Channels}
+	if err = p.shader.CompileSources(vert, frag, self.quad); err != nil {
+		return nil, frag.Dependencies, err
+	}
+
+	// sources rarely use every input
+	p.shader.Reflection.Ignore(shadertoyUniforms...)
+
+	return p, frag.Dependencies, nil
+}
+
+// watch the directories of every dependency, editors often save by renaming
+func (self *ShadertoyProgram) watch(dependencies map[string]bool) {
+	self.dependencies = dependencies
+	for dep := range dependencies {
+		dir := filepath.Dir(dep)
+		if self.watched[dir] {
+			continue
+		}
+
+		if err := self.watcher.Watcher.Add(dir); err != nil {
+			log.Println("shadertoy:", err)
+			continue
+		}
+
+		self.watched[dir] = true
+	}
+}
+
+// loadTexture load a channel's texture file once
+func loadTexture(textures map[string]*Texture, c *Channel) (*Texture, error) {
+	key := textureKey(c)
+	if tex, ok := textures[key]; ok {
+		return tex, nil
+	}
+
+	// textures are uploaded first row at v = 0, flip so the top of the image is at the top
+	options := MipmapTextureOptions
+	options.FlipY = c.VFlip == nil || *c.VFlip
+
+	tex, err := LoadTextureFile(c.Texture, options)
+	if err != nil {
+		return nil, err
+	}
+
+	textures[key] = tex
+	return tex, nil
+}
+
+func textureKey(c *Channel) string {
+	return fmt.Sprintf("%v:%v", c.Texture, c.VFlip == nil || *c.VFlip)
+}
+
+func deleteTextures(textures map[string]*Texture) {
+	for _, tex := range textures {
+		State.DeleteTextures(tex.Handle)
+	}
+}
+
+func (self *ShadertoyProgram) handle(files []string, ok bool) {
+	if !ok {
+		return
+	}
+
+	for _, f := range files {
+		if self.dependencies[AbsPath(f)] {
+			log.Println("modified file:", f)
+			if err := self.reload(); err != nil {
+				log.Println(err)
+			}
+
+			return
+		}
+	}
+}
+
+func (self *ShadertoyProgram) Render(t float64) {
+	select {
+	case files, ok := <-self.watcher.Changes:
+		self.handle(files, ok)
+	default:
+	}
+
+	// rewinding the clock restarts the simulation
+	if self.Clock.Frame == 0 && self.frame != 0 {
+		self.frame = 0
+		self.clear()
+	}
+
+	self.updateMouse()
+	self.render(t)
+	self.frame++
+}
+
+func (self *ShadertoyProgram) RenderPaused(t float64) bool {
+	select {
+	case files, ok := <-self.watcher.Changes:
+		// redraw the paused frame with the new passes or their errors
+		self.handle(files, ok)
+		self.render(t)
+		return true
+	default:
+		return false
+	}
+}
+
+func (self *ShadertoyProgram) render(t float64) {
+	width, height := self.Width, self.Height
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	seconds := now.Sub(midnight).Seconds()
+
+	delta := self.Clock.Delta()
+	frameRate := self.Clock.FPS
+	if delta > 0 {
+		frameRate = 1 / delta
+	}
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	State.BindVertexArray(self.quad.VAO())
+	for _, p := range self.passes {
+		if p.target != nil {
+			p.target.Bind()
+		} else {
+			State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+			gl.Viewport(0, 0, int32(width), int32(height))
+		}
+
+		resolutions := make([]float32, 12)
+		for i, c := range p.channels {
+			tex, mipmaps := self.channelTexture(c)
+			if tex == nil {
+				continue
+			}
+
+			tex.SetOptions(channelOptions(c, mipmaps)).Activate(gl.TEXTURE0 + uint32(i))
+
+			resolutions[i*3] = float32(tex.Width)
+			resolutions[i*3+1] = float32(tex.Height)
+			resolutions[i*3+2] = 1
+		}
+
+		p.shader.Use().
+			Uniform3f("iResolution", float32(width), float32(height), 1).
+			Uniform1f("iTime", float32(t)).
+			Uniform1f("iTimeDelta", float32(delta)).
+			Uniform1f("iFrameRate", float32(frameRate)).
+			Uniform1i("iFrame", self.frame).
+			Uniform1fv("iChannelTime", []float32{float32(t), float32(t), float32(t), float32(t)}).
+			Uniform3fv("iChannelResolution", resolutions).
+			Uniform4f("iMouse", self.mouse[0], self.mouse[1], self.mouse[2], self.mouse[3]).
+			Uniform4f("iDate", float32(now.Year()), float32(now.Month()-1), float32(now.Day()), float32(seconds)).
+			Uniform1f("iSampleRate", 44100).
+			Uniform1i("iChannel0", 0).
+			Uniform1i("iChannel1", 1).
+			Uniform1i("iChannel2", 2).
+			Uniform1i("iChannel3", 3)
+		self.quad.Draw()
+
+		// later passes read this frame
+		if p.target != nil {
+			p.target.Swap()
+		}
+	}
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	self.overlay.Draw(width, height)
+}
+
+// channelTexture texture bound to a channel and whether it has mipmaps
+func (self *ShadertoyProgram) channelTexture(c *Channel) (*Texture, bool) {
+	switch {
+	case c == nil:
+		return nil, false
+	case c.Buffer != "":
+		if p := self.buffers[c.Buffer]; p != nil {
+			return p.target.Read(), false
+		}
+	case c.Texture != "":
+		if tex := self.textures[textureKey(c)]; tex != nil {
+			return tex, true
+		}
+	}
+
+	return nil, false
+}
+
+// channelOptions sampling of a channel's texture
+func channelOptions(c *Channel, mipmaps bool) TextureOptions {
+	options := LinearTextureOptions
+	options.Mipmaps = mipmaps
+	switch c.Filter {
+	case "nearest":
+		options.MinFilter, options.MagFilter = gl.NEAREST, gl.NEAREST
+	case "mipmap":
+		if mipmaps {
+			options.MinFilter = gl.LINEAR_MIPMAP_LINEAR
+		}
+	}
+
+	if c.Wrap == "repeat" {
+		options.WrapS, options.WrapT = gl.REPEAT, gl.REPEAT
+	}
+
+	return options
+}
+
+// updateMouse follow shadertoy's iMouse, xy is the position while pressed and zw where it was pressed.
+// z is negative once released and w only positive on the frame it was pressed.
+func (self *ShadertoyProgram) updateMouse() {
+	x, y := self.Surface.GetCursorPos()
+	mx, my := float32(x), float32(self.Height)-float32(y)
+	down := self.Surface.GetMouseButton(glfw.MouseButton1) == glfw.Press
+
+	switch {
+	case down && !self.mouseDown:
+		self.mouse = [4]float32{mx, my, mx, my}
+	case down:
+		self.mouse[0], self.mouse[1] = mx, my
+		self.mouse[3] = -float32(math.Abs(float64(self.mouse[3])))
+	case self.mouseDown:
+		self.mouse[2] = -float32(math.Abs(float64(self.mouse[2])))
+		self.mouse[3] = -float32(math.Abs(float64(self.mouse[3])))
+	}
+
+	self.mouseDown = down
+}
+
+// clear the buffers of every pass
+func (self *ShadertoyProgram) clear() {
+	for _, p := range self.buffers {
+		for i := 0; i < 2; i++ {
+			p.target.Bind()
+			gl.ClearColor(0, 0, 0, 0)
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+			p.target.Swap()
+		}
+	}
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+}
+
+func (self *shadertoyPass) Cleanup() {
+	self.shader.Cleanup()
+	if self.target != nil {
+		self.target.Cleanup()
+	}
+}
+
+func (self *ShadertoyProgram) ResizeCallback(w *glfw.Window, width int, height int) {
+	for _, p := range self.buffers {
+		p.target.Resize(width, height)
+	}
+}
+
+func (self *ShadertoyProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeySpace && action == glfw.Release {
+		self.Clock.TogglePause()
+	}
+}
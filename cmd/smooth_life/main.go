@@ -3,8 +3,10 @@ package main
 import (
 	"image"
 	"image/color"
+	"log"
 	"math"
 	"math/rand"
+	"os"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -16,6 +18,9 @@ import (
 	_ "embed"
 )
 
+// SeedFile picture the simulation starts from instead of noise, set by the SMOOTH_LIFE_SEED environment variable
+var SeedFile = os.Getenv("SMOOTH_LIFE_SEED")
+
 func init() {
 	HotProgram = NewSmoothLifeProgram()
 }
@@ -71,17 +76,17 @@ func NewSmoothLifeRuleSet() *SmoothLifeRules {
 }
 
 type SmoothLifeProgram struct {
-	Window *glfw.Window
+	Window Surface
+	Clock  *Clock
 
 	// state
 	rules      *SmoothLifeRules
 	frame      int32
-	paused     bool
 	cursorSize float64
 	cmds       CmdChannels
 
 	// textures
-	textureA *Texture
+	state    *PingPong
 	textureB *Texture
 	textureC *Texture
 
@@ -108,7 +113,6 @@ func NewSmoothLifeProgram() Program {
 		rules: NewSmoothLifeRuleSet(),
 
 		frame:      0,
-		paused:     false,
 		cursorSize: 0.025,
 
 		cmds:          cmds,
@@ -117,13 +121,14 @@ func NewSmoothLifeProgram() Program {
 }
 
 func (self *SmoothLifeProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Clock = r.Clock
+	self.Load(r.Surface)
 }
 
-func (self *SmoothLifeProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *SmoothLifeProgram) Load(surface Surface) {
+	self.Window = surface
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	width, height := window.GetFramebufferSize()
+	width, height := surface.GetFramebufferSize()
 
 	// create textures
 	img1 := *image.NewRGBA(image.Rect(0, 0, width, height))
@@ -143,10 +148,22 @@ func (self *SmoothLifeProgram) Load(window *glfw.Window) {
 		}
 	}
 
-	// create compute textures
-	self.textureA = LoadTexture(&img1)
-	self.textureB = LoadTexture(&img2)
-	self.textureC = LoadTexture(&img3)
+	// create compute textures, float so the continuous state is not quantised
+	self.state = NewPingPong(width, height, gl.RGBA32F)
+	self.state.Rescale = true
+	self.state.Fill(&img1)
+	if SeedFile != "" {
+		self.seed(SeedFile)
+	}
+
+	var err error
+	if self.textureB, err = LoadTextureData(width, height, gl.RGBA32F, &img2); err != nil {
+		panic(err)
+	}
+
+	if self.textureC, err = LoadTextureData(width, height, gl.RGBA32F, &img3); err != nil {
+		panic(err)
+	}
 
 	// create compute shaders
 	self.smoothShader = MustCompileShader(VertexShader, SmoothShader, self.bo)
@@ -168,17 +185,29 @@ func (self *SmoothLifeProgram) Load(window *glfw.Window) {
 
 	// create framebuffers
 	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 
 	self.Window.SetScrollCallback(self.ScrollCallback)
 }
 
+// seed replace the state with a picture scaled to fit
+func (self *SmoothLifeProgram) seed(name string) {
+	seed, err := LoadTextureFile(name, TextureOptions{FlipY: true})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	self.state.Copy(seed)
+	State.DeleteTextures(seed.Handle)
+}
+
 func (self *SmoothLifeProgram) recolor() {
 	width, height := self.Window.GetFramebufferSize()
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
-	self.textureA.Activate(gl.TEXTURE0)
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
 		Uniform1i("index", *self.gradientIndex.Current()).
@@ -194,11 +223,10 @@ func (self *SmoothLifeProgram) smooth(t float64) {
 	mb2 := self.Window.GetMouseButton(glfw.MouseButton2)
 
 	// use smooth life program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.textureA.Handle, 0)
+	self.state.Bind()
 
-	gl.BindVertexArray(self.bo.VAO())
-	self.textureA.Activate(gl.TEXTURE0)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 	self.textureC.Activate(gl.TEXTURE1)
 
 	self.smoothShader.Use().
@@ -212,12 +240,15 @@ func (self *SmoothLifeProgram) smooth(t float64) {
 		Uniform4f("mouse", float32(mx), float32(height)-float32(my), float32(mb1), float32(mb2))
 	self.bo.Draw()
 
+	// swap texture
+	self.state.Swap()
+
 	// use gauss x
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.textureB.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
-	self.textureA.Activate(gl.TEXTURE0)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.gaussX.Use().
 		Apply(self.rules.Apply).
@@ -230,10 +261,10 @@ func (self *SmoothLifeProgram) smooth(t float64) {
 	self.bo.Draw()
 
 	// use gauss y
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.textureC.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindVertexArray(self.bo.VAO())
 	self.textureB.Activate(gl.TEXTURE0)
 
 	self.gaussY.Use().
@@ -247,9 +278,9 @@ func (self *SmoothLifeProgram) smooth(t float64) {
 	self.bo.Draw()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
-	self.textureA.Activate(gl.TEXTURE0)
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
+	self.state.Read().Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
 		Uniform1i("index", *self.gradientIndex.Current()).
@@ -263,16 +294,21 @@ func (self *SmoothLifeProgram) Render(t float64) {
 	case <-self.cmds[RecolorCmd]:
 		self.recolor()
 	default:
-		if self.paused {
-			self.bo.Draw()
-			return
-		}
-
 		self.frame = self.frame + 1
 		self.smooth(t)
 	}
 }
 
+func (self *SmoothLifeProgram) RenderPaused(t float64) bool {
+	select {
+	case <-self.cmds[RecolorCmd]:
+		self.recolor()
+		return true
+	default:
+		return false
+	}
+}
+
 func (self *SmoothLifeProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff float64) {
 	if yoff > 0 {
 		self.cursorSize = math.Max(0, self.cursorSize-0.005)
@@ -282,7 +318,7 @@ func (self *SmoothLifeProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff
 }
 
 func (self *SmoothLifeProgram) ResizeCallback(w *glfw.Window, width int, height int) {
-	self.textureA.Resize(width, height)
+	self.state.Resize(width, height)
 	self.textureB.Resize(width, height)
 	self.textureC.Resize(width, height)
 }
@@ -290,7 +326,7 @@ func (self *SmoothLifeProgram) ResizeCallback(w *glfw.Window, width int, height
 func (self *SmoothLifeProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	if action == glfw.Release {
 		if key == glfw.KeySpace {
-			self.paused = !self.paused
+			self.Clock.TogglePause()
 		}
 
 		if key == glfw.KeyJ {
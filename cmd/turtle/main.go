@@ -74,14 +74,14 @@ func (self *Turtle) Advance() (mgl64.Vec2, mgl64.Vec2) {
 }
 
 type TurtleProgram struct {
-	Window        *glfw.Window
+	Window        Surface
+	Clock         *Clock
 	width, height int
 
 	turtle *Turtle
 
 	// state
 	frame      int32
-	paused     bool
 	cursorSize float64
 	cmds       CmdChannels
 
@@ -108,7 +108,6 @@ func NewTurtleProgram() Program {
 		turtle: NewTurtle(),
 
 		frame:      0,
-		paused:     false,
 		cursorSize: 0.025,
 
 		cmds:          cmds,
@@ -116,12 +115,13 @@ func NewTurtleProgram() Program {
 	}
 }
 func (self *TurtleProgram) LoadR(r *Renderer) {
-	self.Load(r.Window)
+	self.Clock = r.Clock
+	self.Load(r.Surface)
 }
-func (self *TurtleProgram) Load(window *glfw.Window) {
-	self.Window = window
+func (self *TurtleProgram) Load(surface Surface) {
+	self.Window = surface
 	self.bo = NewV4Buffer(QuadVertices, 2, 4)
-	self.width, self.height = window.GetFramebufferSize()
+	self.width, self.height = surface.GetFramebufferSize()
 
 	// create textures
 	prev := *image.NewRGBA(image.Rect(0, 0, self.width, self.height))
@@ -160,7 +160,7 @@ func (self *TurtleProgram) Load(window *glfw.Window) {
 
 	// create framebuffers
 	gl.GenFramebuffers(1, &self.fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 
 	self.Window.SetScrollCallback(self.ScrollCallback)
 
@@ -177,8 +177,8 @@ func (self *TurtleProgram) recolor() {
 	width, height := self.Window.GetFramebufferSize()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 
 	self.tex.Activate(gl.TEXTURE0)
 	self.outputShaders.Current().Use().
@@ -191,10 +191,10 @@ func (self *TurtleProgram) recolor() {
 func (self *TurtleProgram) run(t float64) {
 	mx, my := self.Window.GetCursorPos()
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, self.tex.Handle, 0)
 
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindVertexArray(self.bo.VAO())
 	self.tex.Activate(gl.TEXTURE0)
 
 	// prev, next := self.turtle.Dot()
@@ -230,8 +230,8 @@ func (self *TurtleProgram) run(t float64) {
 	self.bo.Draw()
 
 	// use copy program
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.BindVertexArray(self.bo.VAO())
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	State.BindVertexArray(self.bo.VAO())
 	self.tex.Activate(gl.TEXTURE0)
 
 	self.outputShaders.Current().Use().
@@ -246,16 +246,21 @@ func (self *TurtleProgram) Render(t float64) {
 	case <-self.cmds[RecolorCmd]:
 		self.recolor()
 	default:
-		if self.paused {
-			self.bo.Draw()
-			return
-		}
-
 		self.frame = self.frame + 1
 		self.run(t)
 	}
 }
 
+func (self *TurtleProgram) RenderPaused(t float64) bool {
+	select {
+	case <-self.cmds[RecolorCmd]:
+		self.recolor()
+		return true
+	default:
+		return false
+	}
+}
+
 func (self *TurtleProgram) ScrollCallback(w *glfw.Window, xoff float64, yoff float64) {
 	if yoff > 0 {
 		self.cursorSize = math.Max(0, self.cursorSize-0.005)
@@ -292,7 +297,7 @@ func (self *TurtleProgram) KeyCallback(w *glfw.Window, key glfw.Key, scancode in
 		}
 
 		if key == glfw.KeySpace {
-			self.paused = !self.paused
+			self.Clock.TogglePause()
 		}
 	}
 }
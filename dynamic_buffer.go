@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// DynamicBuffer a VBuffer whose vertices change after upload, e.g. gl.DYNAMIC_DRAW for data
+// edited now and then or gl.STREAM_DRAW for data replaced every frame
+type DynamicBuffer struct {
+	VBuffer
+	Usage uint32
+	// Capacity bytes allocated, Update writes within it
+	Capacity int
+}
+
+// NewDynamicBuffer vertices of any layout drawn in order as mode, which can be rewritten with
+// Update or replaced with Orphan
+func NewDynamicBuffer(vertices Vertices, layout VertexLayout, mode, usage uint32) (*DynamicBuffer, error) {
+	if err := checkDrawMode(mode); err != nil {
+		return nil, err
+	}
+
+	switch usage {
+	case gl.STATIC_DRAW, gl.DYNAMIC_DRAW, gl.STREAM_DRAW:
+	default:
+		return nil, fmt.Errorf("buffer usage 0x%x: gl.STATIC_DRAW, gl.DYNAMIC_DRAW or gl.STREAM_DRAW", usage)
+	}
+
+	defer State.Push().Pop()
+	vao, vbo, count, err := newVertexArray(vertices, layout, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamicBuffer{
+		VBuffer: VBuffer{
+			Tris:   int32(count),
+			Count:  count,
+			Size:   layout[0].Components,
+			Mode:   mode,
+			Layout: layout,
+			vao:    vao,
+			vbo:    vbo,
+		},
+		Usage:    usage,
+		Capacity: vertices.BufferSize(),
+	}, nil
+}
+
+// Update overwrite vertices from offset bytes on, the vertex count is unchanged
+func (self *DynamicBuffer) Update(offset int, data Vertices) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if offset < 0 || offset%F32_SIZE != 0 || offset+data.BufferSize() > self.Capacity {
+		return fmt.Errorf("buffer update of %v bytes at %v: capacity is %v bytes", data.BufferSize(), offset, self.Capacity)
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset, data.BufferSize(), gl.Ptr(data))
+	return nil
+}
+
+// Orphan replace every vertex with data of any size. The old storage is handed back to gl
+// instead of overwritten, so draws still reading it never stall the upload.
+func (self *DynamicBuffer) Orphan(data Vertices) error {
+	if len(data) == 0 {
+		return fmt.Errorf("vertex data is empty")
+	}
+
+	count, err := self.Layout.Vertices(data.BufferSize())
+	if err != nil {
+		return err
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+	// BufferData allocates new storage, orphaning the old
+	gl.BufferData(gl.ARRAY_BUFFER, data.BufferSize(), gl.Ptr(data), self.Usage)
+
+	self.Capacity = data.BufferSize()
+	self.Count = count
+	self.Tris = int32(count)
+	return nil
+}
+
+func (self *DynamicBuffer) Cleanup() {
+	gl.DeleteBuffers(1, &self.vbo)
+	State.DeleteVertexArrays(self.vao)
+}
+
+// StreamRegions regions of a StreamBuffer, the gpu can still be drawing the last two frames
+// while the cpu writes the next
+var StreamRegions = 3
+
+// StreamPersistent map stream buffers once where GL_ARB_buffer_storage is supported
+var StreamPersistent = true
+
+// streamWaitTimeout longest a write waits for the gpu before reusing a region anyway
+const streamWaitTimeout = time.Second
+
+// StreamBuffer vertices generated on the cpu every frame. The buffer is split into
+// StreamRegions regions written in turn, each fenced after the draws that read it, so a write
+// only waits when the gpu is frames behind. With GL_ARB_buffer_storage the buffer stays mapped,
+// otherwise each write maps its region unsynchronized.
+//
+//	stream.Write(vertices)
+//	stream.Draw()
+type StreamBuffer struct {
+	Mode   uint32
+	Layout VertexLayout
+	// RegionSize bytes each write can hold
+	RegionSize int
+	// Persistent the buffer is mapped once for its lifetime
+	Persistent bool
+	// Count of vertices, or instances for instanced layouts, in the last write
+	Count int
+
+	vao, vbo uint32
+	// attached vertex arrays of other buffers also pointed at each write
+	attached []uint32
+	region   int
+	written  bool
+	fences   []uintptr
+	mapped   unsafe.Pointer
+}
+
+// NewStreamBuffer a buffer for writes of up to size bytes of vertices in layout, drawn as mode.
+// mode is only used by the stream's own Draw and DrawRange, buffers it is attached to draw
+// as their own Mode, so a stream only read through Attach can pass any mode such as gl.POINTS.
+func NewStreamBuffer(layout VertexLayout, size int, mode uint32) (*StreamBuffer, error) {
+	if err := checkDrawMode(mode); err != nil {
+		return nil, err
+	}
+
+	if _, err := layout.Vertices(size); err != nil {
+		return nil, err
+	}
+
+	if StreamRegions < 1 {
+		return nil, fmt.Errorf("stream buffer needs at least 1 region, StreamRegions is %v", StreamRegions)
+	}
+
+	// regions start aligned for every attribute type
+	size = (size + 15) &^ 15
+	total := size * StreamRegions
+
+	self := &StreamBuffer{
+		Mode:       mode,
+		Layout:     layout,
+		RegionSize: size,
+		Persistent: StreamPersistent && hasExtension("GL_ARB_buffer_storage"),
+		fences:     make([]uintptr, StreamRegions),
+	}
+
+	defer State.Push().Pop()
+	gl.GenVertexArrays(1, &self.vao)
+	State.BindVertexArray(self.vao)
+
+	gl.GenBuffers(1, &self.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+	if self.Persistent {
+		flags := uint32(gl.MAP_WRITE_BIT | gl.MAP_PERSISTENT_BIT | gl.MAP_COHERENT_BIT)
+		gl.BufferStorage(gl.ARRAY_BUFFER, total, nil, flags)
+		self.mapped = gl.MapBufferRange(gl.ARRAY_BUFFER, 0, total, flags)
+		if self.mapped == nil {
+			log.Printf("NewStreamBuffer: persistent map failed with error 0x%x, mapping per write\n", gl.GetError())
+			gl.DeleteBuffers(1, &self.vbo)
+			gl.GenBuffers(1, &self.vbo)
+			gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+			self.Persistent = false
+		}
+	}
+
+	if !self.Persistent {
+		gl.BufferData(gl.ARRAY_BUFFER, total, nil, gl.STREAM_DRAW)
+	}
+
+	layout.apply()
+	return self, nil
+}
+
+func hasExtension(name string) bool {
+	var count int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &count)
+	for i := uint32(0); i < uint32(count); i++ {
+		if gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i)) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Attach also point the vertex array of another buffer at each write, so its vertices can be
+// drawn instanced with the stream's per-instance attributes, as the other buffer's Mode
+func (self *StreamBuffer) Attach(bo BufferObject) {
+	self.attached = append(self.attached, bo.VAO())
+	if self.written {
+		self.point(bo.VAO(), self.region*self.RegionSize)
+	}
+}
+
+// Write copy data into the next region, draws issued before the following Write read it
+func (self *StreamBuffer) Write(data Vertices) error {
+	if len(data) == 0 {
+		self.Count = 0
+		return nil
+	}
+
+	count, err := self.Layout.Vertices(data.BufferSize())
+	if err != nil {
+		return err
+	}
+
+	if data.BufferSize() > self.RegionSize {
+		return fmt.Errorf("stream write of %v bytes: regions hold %v bytes", data.BufferSize(), self.RegionSize)
+	}
+
+	// the draws reading the last region have been issued since it was written
+	if self.written {
+		self.fences[self.region] = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+		self.region = (self.region + 1) % len(self.fences)
+	}
+
+	self.wait(self.region)
+
+	offset := self.region * self.RegionSize
+	if self.Persistent {
+		dst := unsafe.Slice((*float32)(unsafe.Add(self.mapped, offset)), len(data))
+		copy(dst, data)
+	} else {
+		gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+		ptr := gl.MapBufferRange(gl.ARRAY_BUFFER, offset, data.BufferSize(),
+			gl.MAP_WRITE_BIT|gl.MAP_INVALIDATE_RANGE_BIT|gl.MAP_UNSYNCHRONIZED_BIT)
+		if ptr == nil {
+			return fmt.Errorf("stream write: map failed with error 0x%x", gl.GetError())
+		}
+
+		copy(unsafe.Slice((*float32)(ptr), len(data)), data)
+		gl.UnmapBuffer(gl.ARRAY_BUFFER)
+	}
+
+	for _, vao := range append([]uint32{self.vao}, self.attached...) {
+		self.point(vao, offset)
+	}
+
+	self.Count = count
+	self.written = true
+	return nil
+}
+
+// wait block until the gpu is done with a region
+func (self *StreamBuffer) wait(region int) {
+	fence := self.fences[region]
+	if fence == 0 {
+		return
+	}
+
+	switch gl.ClientWaitSync(fence, gl.SYNC_FLUSH_COMMANDS_BIT, uint64(streamWaitTimeout.Nanoseconds())) {
+	case gl.TIMEOUT_EXPIRED:
+		log.Printf("StreamBuffer: region %v still in use after %v\n", region, streamWaitTimeout)
+	case gl.WAIT_FAILED:
+		log.Printf("StreamBuffer: waiting on region %v failed with error 0x%x\n", region, gl.GetError())
+	}
+
+	gl.DeleteSync(fence)
+	self.fences[region] = 0
+}
+
+// point the layout of a vertex array at a region
+func (self *StreamBuffer) point(vao uint32, offset int) {
+	defer State.Push().Pop()
+	State.BindVertexArray(vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+	self.Layout.applyAt(offset)
+}
+
+// Draw the vertices of the last write
+func (self *StreamBuffer) Draw() {
+	self.DrawRange(DrawRange{Count: int32(self.Count)})
+}
+
+func (self *StreamBuffer) DrawRange(r DrawRange) {
+	first := r.First + r.BaseVertex
+	if first < 0 || r.Count < 0 || int(first+r.Count) > self.Count {
+		log.Printf("DrawRange: vertices %v to %v of %v\n", first, first+r.Count, self.Count)
+		return
+	}
+
+	State.BindVertexArray(self.vao)
+	if r.Instances > 1 {
+		gl.DrawArraysInstanced(self.Mode, first, r.Count, r.Instances)
+	} else {
+		gl.DrawArrays(self.Mode, first, r.Count)
+	}
+}
+
+func (self *StreamBuffer) VAO() uint32 {
+	return self.vao
+}
+
+func (self *StreamBuffer) VBO() uint32 {
+	return self.vbo
+}
+
+func (self *StreamBuffer) IBO() uint32 {
+	return 0
+}
+
+func (self *StreamBuffer) Cleanup() {
+	for i := range self.fences {
+		if self.fences[i] != 0 {
+			gl.DeleteSync(self.fences[i])
+			self.fences[i] = 0
+		}
+	}
+
+	if self.Persistent && self.mapped != nil {
+		gl.BindBuffer(gl.ARRAY_BUFFER, self.vbo)
+		gl.UnmapBuffer(gl.ARRAY_BUFFER)
+		self.mapped = nil
+	}
+
+	gl.DeleteBuffers(1, &self.vbo)
+	State.DeleteVertexArrays(self.vao)
+}
@@ -0,0 +1,374 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/lzw"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ericpauley/go-quantize/quantize"
+	"github.com/icza/mjpeg"
+	"golang.org/x/image/draw"
+)
+
+// FrameEncoder writes frames to an output as they arrive
+type FrameEncoder interface {
+	// Encode add a frame shown for duration
+	Encode(img *image.RGBA, duration time.Duration) error
+	// Close finish writing the output
+	Close() error
+	// Name of the output written
+	Name() string
+}
+
+// PNGSequenceEncoder writes every frame to its own numbered png.
+// Frame timings are listed in frames.ffconcat for use with ffmpeg's concat demuxer.
+type PNGSequenceEncoder struct {
+	Folder string
+	frame  int
+
+	concat *os.File
+}
+
+func NewPNGSequenceEncoder(folder string) (*PNGSequenceEncoder, error) {
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+
+	concat, err := os.Create(filepath.Join(folder, "frames.ffconcat"))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = fmt.Fprintln(concat, "ffconcat version 1.0"); err != nil {
+		concat.Close()
+		return nil, err
+	}
+
+	return &PNGSequenceEncoder{Folder: folder, concat: concat}, nil
+}
+
+func (self *PNGSequenceEncoder) Encode(img *image.RGBA, duration time.Duration) error {
+	file := fmt.Sprintf("%06d.png", self.frame)
+	name := filepath.Join(self.Folder, file)
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	// favor speed, sequences get large
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err = enc.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	self.frame++
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(self.concat, "file '%v'\nduration %.6f\n", file, duration.Seconds())
+	return err
+}
+
+func (self *PNGSequenceEncoder) Close() error {
+	return self.concat.Close()
+}
+
+func (self *PNGSequenceEncoder) Name() string {
+	return self.Folder
+}
+
+// MJPEGEncoder writes frames to a motion jpeg avi at a constant frame rate.
+// Frames are repeated or skipped so each is shown for its duration.
+type MJPEGEncoder struct {
+	name  string
+	fps   float64
+	video mjpeg.AviWriter
+	buf   bytes.Buffer
+
+	elapsed time.Duration
+	written int
+}
+
+func NewMJPEGEncoder(name string, width, height int, fps float64) (*MJPEGEncoder, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return nil, err
+	}
+
+	video, err := mjpeg.New(name, int32(width), int32(height), int32(math.Round(fps)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MJPEGEncoder{name: name, fps: math.Round(fps), video: video}, nil
+}
+
+func (self *MJPEGEncoder) Encode(img *image.RGBA, duration time.Duration) error {
+	// frames due by the end of this one
+	self.elapsed += duration
+	due := int(math.Round(self.elapsed.Seconds() * self.fps))
+	if due <= self.written {
+		return nil
+	}
+
+	self.buf.Reset()
+	if err := jpeg.Encode(&self.buf, img, nil); err != nil {
+		return err
+	}
+
+	for ; self.written < due; self.written++ {
+		if err := self.video.AddFrame(self.buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *MJPEGEncoder) Close() error {
+	return self.video.Close()
+}
+
+func (self *MJPEGEncoder) Name() string {
+	return self.name
+}
+
+// GIFEncoder streams frames to an animated gif, each frame quantized to its own palette
+type GIFEncoder struct {
+	name string
+	file *os.File
+	w    *bufio.Writer
+
+	width, height int
+	quantizer     quantize.MedianCutQuantizer
+	err           error
+
+	// delays are centiseconds, carry rounding so playback doesn't drift
+	elapsed time.Duration
+	written int
+
+	// pending latest frame too short to write yet, shown if no later frame is
+	pending *image.RGBA
+}
+
+func NewGIFEncoder(name string, width, height int) (*GIFEncoder, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &GIFEncoder{
+		name:   name,
+		file:   f,
+		w:      bufio.NewWriter(f),
+		width:  width,
+		height: height,
+	}
+
+	// header and logical screen, no global color table
+	enc.write([]byte("GIF89a"))
+	enc.write([]byte{
+		byte(width), byte(width >> 8),
+		byte(height), byte(height >> 8),
+		0x00, 0x00, 0x00,
+	})
+
+	// loop forever
+	enc.write([]byte{0x21, 0xff, 0x0b})
+	enc.write([]byte("NETSCAPE2.0"))
+	enc.write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+
+	if enc.err != nil {
+		f.Close()
+		return nil, enc.err
+	}
+
+	return enc, nil
+}
+
+func (self *GIFEncoder) write(b []byte) {
+	if self.err == nil {
+		_, self.err = self.w.Write(b)
+	}
+}
+
+// Encode write a frame shown for duration. Viewers treat delays under 2cs as 10cs, so frames
+// are dropped until at least 2cs have passed and the next written frame is held for the total.
+func (self *GIFEncoder) Encode(img *image.RGBA, duration time.Duration) error {
+	self.elapsed += duration
+	delay := int(math.Round(self.elapsed.Seconds()*100)) - self.written
+	if delay < 2 {
+		if self.pending == nil || self.pending.Rect != img.Rect {
+			self.pending = image.NewRGBA(img.Rect)
+		}
+
+		copy(self.pending.Pix, img.Pix)
+		return self.err
+	}
+
+	self.written += delay
+	return self.encode(img, delay)
+}
+
+func (self *GIFEncoder) encode(img *image.RGBA, delay int) error {
+	// quantize, the last index is a transparent entry
+	p := self.quantizer.Quantize(make(color.Palette, 0, 255), img)
+	for i, c := range p {
+		// gif colors are opaque, only the transparent entry matches transparent pixels
+		r, g, b, _ := c.RGBA()
+		p[i] = color.RGBA{byte(r >> 8), byte(g >> 8), byte(b >> 8), 0xff}
+	}
+	p = append(p, color.RGBA{0, 0, 0, 0})
+	transparent := len(p) - 1
+
+	b := img.Bounds()
+	frame := image.NewPaletted(b, p)
+	for i := range frame.Pix {
+		frame.Pix[i] = byte(transparent)
+	}
+	draw.Draw(frame, frame.Rect, img, b.Min, draw.Over)
+
+	// color table size is 2^(bits+1)
+	bits := 0
+	for 1<<(bits+1) < len(p) {
+		bits++
+	}
+
+	// graphic control, restore to background with a transparent index
+	self.write([]byte{
+		0x21, 0xf9, 0x04,
+		0x08 | 0x01,
+		byte(delay), byte(delay >> 8),
+		byte(transparent), 0x00,
+	})
+
+	// image descriptor with local color table
+	self.write([]byte{
+		0x2c,
+		0x00, 0x00, 0x00, 0x00,
+		byte(b.Dx()), byte(b.Dx() >> 8),
+		byte(b.Dy()), byte(b.Dy() >> 8),
+		0x80 | byte(bits),
+	})
+
+	table := make([]byte, 3<<(bits+1))
+	for i, c := range p {
+		r, g, b, _ := c.RGBA()
+		table[3*i+0] = byte(r >> 8)
+		table[3*i+1] = byte(g >> 8)
+		table[3*i+2] = byte(b >> 8)
+	}
+	self.write(table)
+
+	// lzw compressed indices in sub-blocks
+	litWidth := bits + 1
+	if litWidth < 2 {
+		litWidth = 2
+	}
+
+	self.write([]byte{byte(litWidth)})
+	blocks := &gifBlockWriter{w: self.w}
+	lzww := lzw.NewWriter(blocks, lzw.LSB, litWidth)
+	for y := 0; y < b.Dy(); y++ {
+		row := frame.Pix[y*frame.Stride : y*frame.Stride+b.Dx()]
+		if _, err := lzww.Write(row); err != nil {
+			return err
+		}
+	}
+
+	if err := lzww.Close(); err != nil {
+		return err
+	}
+
+	if err := blocks.Close(); err != nil {
+		return err
+	}
+
+	return self.err
+}
+
+func (self *GIFEncoder) Close() error {
+	// the last frame was too short to write, hold it for the minimum delay
+	if self.pending != nil && int(math.Round(self.elapsed.Seconds()*100)) > self.written {
+		self.written += 2
+		if err := self.encode(self.pending, 2); err != nil && self.err == nil {
+			self.err = err
+		}
+	}
+
+	self.write([]byte{0x3b})
+	if self.err == nil {
+		self.err = self.w.Flush()
+	}
+
+	if err := self.file.Close(); self.err == nil {
+		self.err = err
+	}
+
+	return self.err
+}
+
+func (self *GIFEncoder) Name() string {
+	return self.name
+}
+
+// gifBlockWriter splits data into length prefixed sub-blocks of at most 255 bytes
+type gifBlockWriter struct {
+	w   io.Writer
+	buf [256]byte
+	n   int
+}
+
+func (self *gifBlockWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		c := copy(self.buf[1+self.n:], p)
+		self.n += c
+		p = p[c:]
+		written += c
+
+		if self.n == 255 {
+			if err := self.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+func (self *gifBlockWriter) flush() error {
+	if self.n == 0 {
+		return nil
+	}
+
+	self.buf[0] = byte(self.n)
+	_, err := self.w.Write(self.buf[:1+self.n])
+	self.n = 0
+	return err
+}
+
+// Close flush remaining data and write the block terminator
+func (self *gifBlockWriter) Close() error {
+	if err := self.flush(); err != nil {
+		return err
+	}
+
+	_, err := self.w.Write([]byte{0x00})
+	return err
+}
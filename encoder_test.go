@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGIFEncoderTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		fps      float64
+		frames   int
+		duration int // centiseconds
+	}{
+		{"60 fps", 60, 60, 100},
+		{"30 fps", 30, 90, 300},
+		{"144 fps", 144, 144, 100},
+		{"25 fps", 25, 5, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "a.gif")
+			enc, err := NewGIFEncoder(name, 8, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+			step := time.Duration(float64(time.Second) / tt.fps)
+			for i := 0; i < tt.frames; i++ {
+				img.Set(i%8, 0, color.RGBA{byte(i * 10), 0, 0, 255})
+				if err := enc.Encode(img, step); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			g := decodeGIF(t, name)
+			total := 0
+			for i, d := range g.Delay {
+				if d < 2 {
+					t.Errorf("frame %v: delay %vcs, viewers slow delays under 2cs", i, d)
+				}
+				total += d
+			}
+
+			// within the 2cs the last frame may be held
+			if total < tt.duration || total > tt.duration+2 {
+				t.Errorf("plays for %vcs over %v frames, want %vcs", total, len(g.Delay), tt.duration)
+			}
+		})
+	}
+}
+
+func TestGIFEncoderPalette(t *testing.T) {
+	fill := func(c color.RGBA) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	}
+
+	tests := []struct {
+		name string
+		img  *image.RGBA
+	}{
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0))},
+		{"transparent", fill(color.RGBA{})},
+		{"opaque black", fill(color.RGBA{0, 0, 0, 255})},
+		{"opaque red", fill(color.RGBA{255, 0, 0, 255})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "a.gif")
+			enc, err := NewGIFEncoder(name, tt.img.Rect.Dx(), tt.img.Rect.Dy())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := enc.Encode(tt.img, time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.img.Rect.Empty() {
+				return
+			}
+
+			g := decodeGIF(t, name)
+			want := tt.img.RGBAAt(0, 0)
+			got := color.RGBAModel.Convert(g.Image[0].At(0, 0)).(color.RGBA)
+			if got != want {
+				t.Errorf("pixel is %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func decodeGIF(t *testing.T, name string) *gif.GIF {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
@@ -0,0 +1,546 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+func init() {
+	image.RegisterFormat("exr", "v/1\x01", DecodeEXRImage, DecodeEXRConfig)
+}
+
+// EXRCompression of OpenEXR scanline blocks, only those supported
+type EXRCompression byte
+
+const (
+	EXRNone EXRCompression = 0
+	// EXRZips zlib compressed single scanlines
+	EXRZips EXRCompression = 2
+	// EXRZip zlib compressed blocks of 16 scanlines
+	EXRZip EXRCompression = 3
+)
+
+func (self EXRCompression) lines() int {
+	if self == EXRZip {
+		return 16
+	}
+
+	return 1
+}
+
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+// EXROptions how EncodeEXR writes an image, nil writes zip compressed half floats
+type EXROptions struct {
+	Compression EXRCompression
+	// Float write 32 bit floats instead of half floats
+	Float bool
+}
+
+type exrChannel struct {
+	name      string
+	pixelType int32
+}
+
+func (self exrChannel) size() int {
+	if self.pixelType == exrHalf {
+		return 2
+	}
+
+	return 4
+}
+
+type exrHeader struct {
+	channels    []exrChannel
+	compression EXRCompression
+	dataWindow  image.Rectangle
+}
+
+// DecodeEXR read a single part scanline OpenEXR image with no, zips or zip compression.
+// R, G, B and A channels are read, Y as gray, missing channels are 0 and missing alpha 1.
+func DecodeEXR(r io.Reader) (*HDRImage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, offset, err := readEXRHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := header.dataWindow.Dx(), header.dataWindow.Dy()
+	lines := header.compression.lines()
+	chunks := (height + lines - 1) / lines
+	if offset+8*chunks > len(data) {
+		return nil, errors.New("exr: truncated offset table")
+	}
+
+	pixelSize := 0
+	for _, c := range header.channels {
+		pixelSize += c.size()
+	}
+
+	// zlib expands data at most about 1032 times, larger windows can't be filled by the file
+	if pixelSize == 0 || float64(width)*float64(height)*float64(pixelSize) > 1032*float64(len(data)) {
+		return nil, fmt.Errorf("exr: %vx%v data window is larger than the file holds", width, height)
+	}
+
+	lineSize := pixelSize * width
+	img := NewHDRImage(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 1
+		}
+	}
+
+	for i := 0; i < chunks; i++ {
+		at := int(binary.LittleEndian.Uint64(data[offset+8*i:]))
+		if at < 0 || at+8 > len(data) {
+			return nil, errors.New("exr: bad chunk offset")
+		}
+
+		y := int(int32(binary.LittleEndian.Uint32(data[at:]))) - header.dataWindow.Min.Y
+		size := int(binary.LittleEndian.Uint32(data[at+4:]))
+		if at+8+size > len(data) || y < 0 || y >= height {
+			return nil, errors.New("exr: bad chunk")
+		}
+
+		n := lines
+		if y+n > height {
+			n = height - y
+		}
+
+		block, err := decompressEXR(data[at+8:at+8+size], n*lineSize)
+		if err != nil {
+			return nil, fmt.Errorf("exr: chunk %v: %v", i, err)
+		}
+
+		readEXRBlock(img, header.channels, block, y, n)
+	}
+
+	return img, nil
+}
+
+func DecodeEXRImage(r io.Reader) (image.Image, error) {
+	return DecodeEXR(r)
+}
+
+// maxEXRHeaderSize longest header DecodeEXRConfig reads looking for the end of the attributes
+const maxEXRHeaderSize = 1 << 20
+
+func DecodeEXRConfig(r io.Reader) (image.Config, error) {
+	// the header is small, read it without the pixels
+	data := make([]byte, 0, 1024)
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if header, _, herr := readEXRHeader(data); herr == nil {
+			return image.Config{ColorModel: color.NRGBA64Model, Width: header.dataWindow.Dx(), Height: header.dataWindow.Dy()}, nil
+		} else if err != nil {
+			return image.Config{}, herr
+		}
+
+		if n == 0 {
+			return image.Config{}, io.ErrNoProgress
+		}
+
+		if len(data) > maxEXRHeaderSize {
+			return image.Config{}, errors.New("exr: header is too large")
+		}
+	}
+}
+
+// readEXRHeader parse the magic, version and header attributes, returning where the offset table starts
+func readEXRHeader(data []byte) (*exrHeader, int, error) {
+	if len(data) < 8 || string(data[:4]) != "v/1\x01" {
+		return nil, 0, errors.New("exr: not an openexr file")
+	}
+
+	version := binary.LittleEndian.Uint32(data[4:])
+	if version&0xff != 2 {
+		return nil, 0, fmt.Errorf("exr: unsupported version %v", version&0xff)
+	}
+
+	// tiled, deep and multi part files
+	if version&(0x200|0x800|0x1000) != 0 {
+		return nil, 0, errors.New("exr: only single part scanline images are supported")
+	}
+
+	header := &exrHeader{}
+	found := make(map[string]bool)
+	i := 8
+	for {
+		name, next, err := exrString(data, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		i = next
+		if name == "" {
+			break
+		}
+
+		typ, next, err := exrString(data, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		i = next
+		if i+4 > len(data) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[i:]))
+		i += 4
+		if i+size > len(data) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+
+		value := data[i : i+size]
+		i += size
+		found[name] = true
+
+		switch {
+		case name == "channels" && typ == "chlist":
+			if header.channels, err = readEXRChannels(value); err != nil {
+				return nil, 0, err
+			}
+		case name == "compression" && typ == "compression" && size == 1:
+			header.compression = EXRCompression(value[0])
+		case name == "dataWindow" && typ == "box2i" && size == 16:
+			box := make([]int, 4)
+			for j := range box {
+				box[j] = int(int32(binary.LittleEndian.Uint32(value[4*j:])))
+			}
+
+			header.dataWindow = image.Rect(box[0], box[1], box[2]+1, box[3]+1)
+		}
+	}
+
+	for _, required := range []string{"channels", "compression", "dataWindow"} {
+		if !found[required] {
+			return nil, 0, fmt.Errorf("exr: missing %v attribute", required)
+		}
+	}
+
+	switch header.compression {
+	case EXRNone, EXRZips, EXRZip:
+	default:
+		return nil, 0, fmt.Errorf("exr: unsupported compression %v", header.compression)
+	}
+
+	if header.dataWindow.Empty() {
+		return nil, 0, errors.New("exr: empty data window")
+	}
+
+	return header, i, nil
+}
+
+func exrString(data []byte, i int) (string, int, error) {
+	end := bytes.IndexByte(data[i:], 0)
+	if end == -1 {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+
+	return string(data[i : i+end]), i + end + 1, nil
+}
+
+func readEXRChannels(value []byte) ([]exrChannel, error) {
+	channels := make([]exrChannel, 0, 4)
+	for i := 0; ; {
+		name, next, err := exrString(value, i)
+		if err != nil {
+			return nil, err
+		}
+
+		i = next
+		if name == "" {
+			return channels, nil
+		}
+
+		if i+16 > len(value) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		c := exrChannel{name: name, pixelType: int32(binary.LittleEndian.Uint32(value[i:]))}
+		xSampling := binary.LittleEndian.Uint32(value[i+8:])
+		ySampling := binary.LittleEndian.Uint32(value[i+12:])
+		i += 16
+
+		if c.pixelType < exrUint || c.pixelType > exrFloat {
+			return nil, fmt.Errorf("exr: channel %v has unknown pixel type %v", name, c.pixelType)
+		}
+
+		if xSampling != 1 || ySampling != 1 {
+			return nil, fmt.Errorf("exr: channel %v is subsampled", name)
+		}
+
+		channels = append(channels, c)
+	}
+}
+
+// decompressEXR inflate a zip block, blocks that did not compress are stored as is
+func decompressEXR(data []byte, size int) ([]byte, error) {
+	if len(data) == size {
+		return data, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, size)
+	if _, err = io.ReadFull(zr, raw); err != nil {
+		return nil, err
+	}
+
+	// undo the delta predictor
+	for i := 1; i < len(raw); i++ {
+		raw[i] = raw[i-1] + raw[i] - 128
+	}
+
+	// then interleave the two halves
+	out := make([]byte, size)
+	half := (size + 1) / 2
+	for i := range out {
+		if i%2 == 0 {
+			out[i] = raw[i/2]
+		} else {
+			out[i] = raw[half+i/2]
+		}
+	}
+
+	return out, nil
+}
+
+func compressEXR(raw []byte) []byte {
+	// split even and odd bytes into halves
+	t := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			t[i/2] = b
+		} else {
+			t[half+i/2] = b
+		}
+	}
+
+	// delta predictor
+	prev := t[0]
+	for i := 1; i < len(t); i++ {
+		d := t[i] - prev + 128
+		prev = t[i]
+		t[i] = d
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(t)
+	zw.Close()
+
+	if buf.Len() >= len(raw) {
+		return raw
+	}
+
+	return buf.Bytes()
+}
+
+// readEXRBlock copy n scanlines starting at y into img, each line holds every channel in turn
+func readEXRBlock(img *HDRImage, channels []exrChannel, block []byte, y, n int) {
+	width := img.Rect.Dx()
+	i := 0
+	for line := 0; line < n; line++ {
+		pix := img.Pix[(y+line)*img.Stride:]
+		for _, c := range channels {
+			targets := exrTargets(c.name)
+			for x := 0; x < width; x++ {
+				var v float32
+				switch c.pixelType {
+				case exrHalf:
+					v = Float32(binary.LittleEndian.Uint16(block[i:]))
+				case exrFloat:
+					v = math.Float32frombits(binary.LittleEndian.Uint32(block[i:]))
+				default:
+					v = float32(binary.LittleEndian.Uint32(block[i:]))
+				}
+
+				i += c.size()
+				for _, t := range targets {
+					pix[4*x+t] = v
+				}
+			}
+		}
+	}
+}
+
+// exrTargets rgba indices a channel is read into, layer prefixes such as "diffuse." are ignored
+func exrTargets(name string) []int {
+	if dot := strings.LastIndexByte(name, '.'); dot != -1 {
+		name = name[dot+1:]
+	}
+
+	switch name {
+	case "R":
+		return []int{0}
+	case "G":
+		return []int{1}
+	case "B":
+		return []int{2}
+	case "A":
+		return []int{3}
+	case "Y":
+		return []int{0, 1, 2}
+	}
+
+	return nil
+}
+
+// EncodeEXR write img as a single part scanline OpenEXR image with R, G, B and A channels
+func EncodeEXR(w io.Writer, img *HDRImage, o *EXROptions) error {
+	if o == nil {
+		o = &EXROptions{Compression: EXRZip}
+	}
+
+	switch o.Compression {
+	case EXRNone, EXRZips, EXRZip:
+	default:
+		return fmt.Errorf("exr: unsupported compression %v", o.Compression)
+	}
+
+	pixelType := int32(exrHalf)
+	if o.Float {
+		pixelType = exrFloat
+	}
+
+	// channels are stored in alphabetical order
+	channels := []exrChannel{{"A", pixelType}, {"B", pixelType}, {"G", pixelType}, {"R", pixelType}}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	var header bytes.Buffer
+	header.Write([]byte("v/1\x01"))
+	binary.Write(&header, binary.LittleEndian, uint32(2))
+
+	var chlist bytes.Buffer
+	for _, c := range channels {
+		chlist.WriteString(c.name)
+		chlist.WriteByte(0)
+		binary.Write(&chlist, binary.LittleEndian, c.pixelType)
+		// pLinear and reserved, then x and y sampling
+		chlist.Write([]byte{0, 0, 0, 0})
+		binary.Write(&chlist, binary.LittleEndian, []int32{1, 1})
+	}
+
+	chlist.WriteByte(0)
+
+	box := new(bytes.Buffer)
+	binary.Write(box, binary.LittleEndian, []int32{0, 0, int32(width - 1), int32(height - 1)})
+
+	writeEXRAttribute(&header, "channels", "chlist", chlist.Bytes())
+	writeEXRAttribute(&header, "compression", "compression", []byte{byte(o.Compression)})
+	writeEXRAttribute(&header, "dataWindow", "box2i", box.Bytes())
+	writeEXRAttribute(&header, "displayWindow", "box2i", box.Bytes())
+	// increasing y
+	writeEXRAttribute(&header, "lineOrder", "lineOrder", []byte{0})
+	writeEXRAttribute(&header, "pixelAspectRatio", "float", exrFloats(1))
+	writeEXRAttribute(&header, "screenWindowCenter", "v2f", exrFloats(0, 0))
+	writeEXRAttribute(&header, "screenWindowWidth", "float", exrFloats(1))
+	header.WriteByte(0)
+
+	lines := o.Compression.lines()
+	chunks := make([][]byte, 0, (height+lines-1)/lines)
+	for y := 0; y < height; y += lines {
+		n := lines
+		if y+n > height {
+			n = height - y
+		}
+
+		raw := writeEXRBlock(img, channels, y, n)
+		if o.Compression != EXRNone {
+			raw = compressEXR(raw)
+		}
+
+		chunk := make([]byte, 8, 8+len(raw))
+		binary.LittleEndian.PutUint32(chunk, uint32(y))
+		binary.LittleEndian.PutUint32(chunk[4:], uint32(len(raw)))
+		chunks = append(chunks, append(chunk, raw...))
+	}
+
+	// offset table of where each chunk starts in the file
+	offsets := make([]uint64, len(chunks))
+	at := uint64(header.Len() + 8*len(chunks))
+	for i, chunk := range chunks {
+		offsets[i] = at
+		at += uint64(len(chunk))
+	}
+
+	binary.Write(&header, binary.LittleEndian, offsets)
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeEXRAttribute(w *bytes.Buffer, name, typ string, value []byte) {
+	w.WriteString(name)
+	w.WriteByte(0)
+	w.WriteString(typ)
+	w.WriteByte(0)
+	binary.Write(w, binary.LittleEndian, uint32(len(value)))
+	w.Write(value)
+}
+
+func exrFloats(values ...float32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+
+	return data
+}
+
+func writeEXRBlock(img *HDRImage, channels []exrChannel, y, n int) []byte {
+	width := img.Rect.Dx()
+	block := make([]byte, 0, n*width*len(channels)*channels[0].size())
+	value := make([]byte, 4)
+	for line := 0; line < n; line++ {
+		pix := img.Pix[(y+line)*img.Stride:]
+		for _, c := range channels {
+			t := exrTargets(c.name)[0]
+			for x := 0; x < width; x++ {
+				v := pix[4*x+t]
+				if c.pixelType == exrHalf {
+					binary.LittleEndian.PutUint16(value, Float16(v))
+					block = append(block, value[:2]...)
+					continue
+				}
+
+				binary.LittleEndian.PutUint32(value, math.Float32bits(v))
+				block = append(block, value...)
+			}
+		}
+	}
+
+	return block
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEXRRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *EXROptions
+		// relative error allowed
+		tolerance float64
+	}{
+		{"default zip half", nil, 1.0 / 1024},
+		{"none half", &EXROptions{Compression: EXRNone}, 1.0 / 1024},
+		{"zips half", &EXROptions{Compression: EXRZips}, 1.0 / 1024},
+		{"none float", &EXROptions{Compression: EXRNone, Float: true}, 0},
+		{"zips float", &EXROptions{Compression: EXRZips, Float: true}, 0},
+		{"zip float", &EXROptions{Compression: EXRZip, Float: true}, 0},
+	}
+
+	// 20 rows is a full and a partial block of 16 for zip
+	want := testHDRImage(9, 20)
+	want.SetFloat(0, 0, [4]float32{-2, 0, 1e-3, 0.5})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeEXR(&buf, want, tt.options); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, format, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := decoded.(*HDRImage)
+			if format != "exr" || !ok {
+				t.Fatalf("decoded %T as %v", decoded, format)
+			}
+
+			if got.Rect != want.Rect {
+				t.Fatalf("bounds %v, want %v", got.Rect, want.Rect)
+			}
+
+			for i, w := range want.Pix {
+				if math.Abs(float64(got.Pix[i]-w)) > math.Abs(float64(w))*tt.tolerance {
+					x, y := i/4%want.Rect.Dx(), i/4/want.Rect.Dx()
+					t.Fatalf("pixel %v,%v is %v, want %v", x, y, got.FloatAt(x, y), want.FloatAt(x, y))
+				}
+			}
+		})
+	}
+}
+
+func TestEXRUnsupportedCompression(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeEXR(&buf, testHDRImage(2, 2), &EXROptions{Compression: 4}); err == nil {
+		t.Error("piz compression accepted")
+	}
+}
+
+// stalledReader returns the start of data then reads nothing, without an error, forever
+type stalledReader struct {
+	data []byte
+}
+
+func (self *stalledReader) Read(p []byte) (int, error) {
+	n := copy(p, self.data)
+	self.data = self.data[n:]
+	return n, nil
+}
+
+func TestEXRCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeEXR(&buf, testHDRImage(2, 2), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := buf.Bytes()
+
+	// a window of 10000000x2 pixels, still one zip block, in a file of a few hundred bytes
+	huge := append([]byte{}, valid...)
+	box := bytes.Index(huge, []byte("dataWindow\x00box2i\x00")) + len("dataWindow\x00box2i\x00") + 4
+	binary.LittleEndian.PutUint32(huge[box+8:], 9999999)
+
+	tests := []struct {
+		name   string
+		reader io.Reader
+		config bool
+		err    string
+	}{
+		{"header cut short by a stalled reader", &stalledReader{valid[:40]}, true, io.ErrNoProgress.Error()},
+		{"data window larger than the file", bytes.NewReader(huge), false, "larger than the file holds"},
+		{"truncated", bytes.NewReader(valid[:len(valid)-4]), false, "bad chunk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.config {
+				_, err = DecodeEXRConfig(tt.reader)
+			} else {
+				_, err = DecodeEXR(tt.reader)
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
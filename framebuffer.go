@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// FramebufferBuilder describes the attachments of a RenderTarget.
+//
+//	target, err := NewFramebufferBuilder(width, height).
+//		Color(gl.RGBA8, LinearTextureOptions).
+//		Color(gl.RGBA16F).
+//		Depth(gl.DEPTH24_STENCIL8).
+//		Samples(4).
+//		Build()
+type FramebufferBuilder struct {
+	width, height int
+	colors        []framebufferAttachment
+	depth         *framebufferAttachment
+	samples       int
+
+	// err first invalid attachment, returned by Build
+	err error
+}
+
+type framebufferAttachment struct {
+	format  TextureFormat
+	options TextureOptions
+}
+
+func NewFramebufferBuilder(width, height int) *FramebufferBuilder {
+	return &FramebufferBuilder{width: width, height: height, samples: 1}
+}
+
+// Color add a color attachment with its own format, attachments are numbered in the
+// order added to match fragment shader outputs by location
+func (self *FramebufferBuilder) Color(internalFormat int32, options ...TextureOptions) *FramebufferBuilder {
+	format, ok := LookupTextureFormat(internalFormat)
+	if !ok || format.attachment() != 0 {
+		self.fail(fmt.Errorf("color attachment %v: unsupported format 0x%x", len(self.colors), internalFormat))
+		return self
+	}
+
+	self.colors = append(self.colors, framebufferAttachment{format, textureOptions(options)})
+	return self
+}
+
+// Depth add a depth attachment shaders can sample, gl.DEPTH_COMPONENT24 or gl.DEPTH_COMPONENT32F,
+// or gl.DEPTH24_STENCIL8 for depth and stencil
+func (self *FramebufferBuilder) Depth(internalFormat int32) *FramebufferBuilder {
+	format, ok := LookupTextureFormat(internalFormat)
+	if !ok || format.attachment() == 0 {
+		self.fail(fmt.Errorf("depth attachment: unsupported format 0x%x", internalFormat))
+		return self
+	}
+
+	self.depth = &framebufferAttachment{format, TextureOptions{
+		MinFilter: gl.NEAREST,
+		MagFilter: gl.NEAREST,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+	}}
+
+	return self
+}
+
+// Samples multisample the attachments, RenderTarget.Resolve copies them into the textures
+func (self *FramebufferBuilder) Samples(samples int) *FramebufferBuilder {
+	self.samples = samples
+	return self
+}
+
+func (self *FramebufferBuilder) fail(err error) {
+	if self.err == nil {
+		self.err = err
+	}
+}
+
+// Build allocate the attachments, errors describe why the framebuffer is incomplete
+func (self *FramebufferBuilder) Build() (*RenderTarget, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	if self.width <= 0 || self.height <= 0 {
+		return nil, fmt.Errorf("framebuffer size %vx%v is empty", self.width, self.height)
+	}
+
+	if len(self.colors) == 0 && self.depth == nil {
+		return nil, fmt.Errorf("framebuffer has no attachments")
+	}
+
+	var maxColors, maxSamples int32
+	gl.GetIntegerv(gl.MAX_COLOR_ATTACHMENTS, &maxColors)
+	gl.GetIntegerv(gl.MAX_SAMPLES, &maxSamples)
+	if len(self.colors) > int(maxColors) {
+		return nil, fmt.Errorf("framebuffer has %v color attachments, at most %v are supported", len(self.colors), maxColors)
+	}
+
+	if self.samples < 1 || self.samples > int(maxSamples) {
+		return nil, fmt.Errorf("framebuffer samples %v outside 1 to %v", self.samples, maxSamples)
+	}
+
+	target := &RenderTarget{builder: *self}
+	if err := target.allocate(self.width, self.height); err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// String the attachments, for errors
+func (self *FramebufferBuilder) String() string {
+	parts := make([]string, 0, len(self.colors)+2)
+	for i, c := range self.colors {
+		parts = append(parts, fmt.Sprintf("color %v %v", i, c.format))
+	}
+
+	if self.depth != nil {
+		parts = append(parts, fmt.Sprintf("depth %v", self.depth.format))
+	}
+
+	if self.samples > 1 {
+		parts = append(parts, fmt.Sprintf("%v samples", self.samples))
+	}
+
+	return fmt.Sprintf("%vx%v %v", self.width, self.height, strings.Join(parts, ", "))
+}
+
+// RenderTarget a framebuffer built by FramebufferBuilder.
+// Draw after Bind, then sample Colors and Depth, calling Resolve first when multisampled.
+type RenderTarget struct {
+	Width, Height int
+	Samples       int
+
+	// Colors textures of the color attachments in the order added
+	Colors []*Texture
+	// Depth texture of the depth attachment, nil without one
+	Depth *Texture
+
+	// Framebuffer drawn into, its attachments are multisampled renderbuffers when Samples > 1
+	*Framebuffer
+
+	// resolved framebuffer the textures are attached to when multisampled
+	resolved      *Framebuffer
+	renderbuffers []uint32
+	builder       FramebufferBuilder
+}
+
+func (self *RenderTarget) allocate(width, height int) (err error) {
+	b := &self.builder
+	b.width, b.height = width, height
+	self.Width, self.Height, self.Samples = width, height, b.samples
+
+	State.Push()
+	defer func() {
+		State.Pop()
+		if err != nil {
+			self.Cleanup()
+		}
+	}()
+
+	// textures are attached to the framebuffer drawn into, or the resolved one
+	textures := NewFramebuffer()
+	self.Framebuffer = textures
+	State.BindFramebuffer(gl.FRAMEBUFFER, textures.Handle)
+
+	self.Colors = make([]*Texture, len(b.colors))
+	for i, c := range b.colors {
+		self.Colors[i] = NewTexture(width, height, c.format.Internal, c.options)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.TEXTURE_2D, self.Colors[i].Handle, 0)
+	}
+
+	if b.depth != nil {
+		self.Depth = NewTexture(width, height, b.depth.format.Internal, b.depth.options)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, b.depth.format.attachment(), gl.TEXTURE_2D, self.Depth.Handle, 0)
+	}
+
+	if err = self.complete(); err != nil {
+		return err
+	}
+
+	if b.samples <= 1 {
+		return nil
+	}
+
+	// draw into multisampled renderbuffers of the same formats
+	self.resolved = textures
+	self.Framebuffer = NewFramebuffer()
+	State.BindFramebuffer(gl.FRAMEBUFFER, self.Framebuffer.Handle)
+
+	attachments := make([]framebufferAttachment, len(b.colors))
+	copy(attachments, b.colors)
+	if b.depth != nil {
+		attachments = append(attachments, *b.depth)
+	}
+
+	self.renderbuffers = make([]uint32, len(attachments))
+	gl.GenRenderbuffers(int32(len(attachments)), &self.renderbuffers[0])
+	for i, a := range attachments {
+		State.BindRenderbuffer(self.renderbuffers[i])
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, int32(b.samples), uint32(a.format.Internal), int32(width), int32(height))
+
+		attachment := gl.COLOR_ATTACHMENT0 + uint32(i)
+		if a.format.attachment() != 0 {
+			attachment = a.format.attachment()
+		}
+
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, self.renderbuffers[i])
+	}
+
+	return self.complete()
+}
+
+// complete enable every color attachment and check the bound framebuffer
+func (self *RenderTarget) complete() error {
+	if len(self.Colors) > 0 {
+		gl.DrawBuffers(int32(len(self.Colors)), &self.drawBuffers()[0])
+	} else {
+		gl.DrawBuffer(gl.NONE)
+		gl.ReadBuffer(gl.NONE)
+	}
+
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("framebuffer %v is incomplete: %v", self.builder.String(), framebufferStatusString(status))
+	}
+
+	return nil
+}
+
+func (self *RenderTarget) drawBuffers() []uint32 {
+	buffers := make([]uint32, len(self.Colors))
+	for i := range buffers {
+		buffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+
+	return buffers
+}
+
+func framebufferStatusString(status uint32) string {
+	switch status {
+	case gl.FRAMEBUFFER_UNDEFINED:
+		return "FRAMEBUFFER_UNDEFINED"
+	case gl.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:
+		return "FRAMEBUFFER_INCOMPLETE_ATTACHMENT, an attachment is not renderable"
+	case gl.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT:
+		return "FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT"
+	case gl.FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER:
+		return "FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER"
+	case gl.FRAMEBUFFER_INCOMPLETE_READ_BUFFER:
+		return "FRAMEBUFFER_INCOMPLETE_READ_BUFFER"
+	case gl.FRAMEBUFFER_UNSUPPORTED:
+		return "FRAMEBUFFER_UNSUPPORTED, the combination of formats is not supported"
+	case gl.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE:
+		return "FRAMEBUFFER_INCOMPLETE_MULTISAMPLE, attachments disagree on samples"
+	case gl.FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS:
+		return "FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS"
+	case 0:
+		return fmt.Sprintf("error 0x%x", gl.GetError())
+	}
+
+	return fmt.Sprintf("status 0x%x", status)
+}
+
+// Bind draw into the target at its size
+func (self *RenderTarget) Bind() {
+	self.Framebuffer.Bind()
+	gl.Viewport(0, 0, int32(self.Width), int32(self.Height))
+}
+
+// Resolve average the samples drawn into Colors and Depth, nothing to do unless multisampled
+func (self *RenderTarget) Resolve() {
+	if self.resolved == nil {
+		return
+	}
+
+	defer State.Push().Pop()
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, self.Framebuffer.Handle)
+	State.BindFramebuffer(gl.DRAW_FRAMEBUFFER, self.resolved.Handle)
+
+	// one color attachment at a time
+	for i := range self.Colors {
+		attachment := gl.COLOR_ATTACHMENT0 + uint32(i)
+		gl.ReadBuffer(attachment)
+		gl.DrawBuffers(1, &attachment)
+		gl.BlitFramebuffer(
+			0, 0, int32(self.Width), int32(self.Height),
+			0, 0, int32(self.Width), int32(self.Height),
+			gl.COLOR_BUFFER_BIT, gl.NEAREST,
+		)
+	}
+
+	if self.Depth != nil {
+		mask := uint32(gl.DEPTH_BUFFER_BIT)
+		if self.Depth.Format.attachment() == gl.DEPTH_STENCIL_ATTACHMENT {
+			mask |= gl.STENCIL_BUFFER_BIT
+		}
+
+		gl.BlitFramebuffer(
+			0, 0, int32(self.Width), int32(self.Height),
+			0, 0, int32(self.Width), int32(self.Height),
+			mask, gl.NEAREST,
+		)
+	}
+
+	if len(self.Colors) > 0 {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+		gl.DrawBuffers(int32(len(self.Colors)), &self.drawBuffers()[0])
+	}
+}
+
+// Resize reallocate every attachment, contents are lost
+func (self *RenderTarget) Resize(width, height int) error {
+	self.Cleanup()
+	return self.allocate(width, height)
+}
+
+func (self *RenderTarget) Cleanup() {
+	for _, tex := range append(self.Colors, self.Depth) {
+		if tex != nil {
+			State.DeleteTextures(tex.Handle)
+		}
+	}
+
+	State.DeleteRenderbuffers(self.renderbuffers...)
+	for _, fbo := range []*Framebuffer{self.Framebuffer, self.resolved} {
+		if fbo != nil {
+			State.DeleteFramebuffers(fbo.Handle)
+		}
+	}
+
+	self.Colors, self.Depth = nil, nil
+	self.Framebuffer, self.resolved = nil, nil
+	self.renderbuffers = nil
+}
@@ -0,0 +1,371 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+func init() {
+	image.RegisterFormat("hdr", "#?", DecodeHDRImage, DecodeHDRConfig)
+}
+
+// HDRImage float RGBA pixels with straight alpha, rows top down like image.RGBA.
+// Values are linear and unbounded, At clamps them to 0 to 1.
+type HDRImage struct {
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+func NewHDRImage(r image.Rectangle) *HDRImage {
+	return &HDRImage{
+		Pix:    make([]float32, 4*r.Dx()*r.Dy()),
+		Stride: 4 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+func (self *HDRImage) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (self *HDRImage) Bounds() image.Rectangle {
+	return self.Rect
+}
+
+func (self *HDRImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(self.Rect)) {
+		return color.NRGBA64{}
+	}
+
+	c := self.FloatAt(x, y)
+	return color.NRGBA64{clampUint16(c[0]), clampUint16(c[1]), clampUint16(c[2]), clampUint16(c[3])}
+}
+
+func (self *HDRImage) PixOffset(x, y int) int {
+	return (y-self.Rect.Min.Y)*self.Stride + (x-self.Rect.Min.X)*4
+}
+
+// FloatAt the unclamped RGBA of a pixel
+func (self *HDRImage) FloatAt(x, y int) [4]float32 {
+	i := self.PixOffset(x, y)
+	return [4]float32{self.Pix[i], self.Pix[i+1], self.Pix[i+2], self.Pix[i+3]}
+}
+
+func (self *HDRImage) SetFloat(x, y int, c [4]float32) {
+	if !(image.Point{x, y}.In(self.Rect)) {
+		return
+	}
+
+	copy(self.Pix[self.PixOffset(x, y):], c[:])
+}
+
+func clampUint16(f float32) uint16 {
+	switch {
+	case f <= 0 || f != f:
+		return 0
+	case f >= 1:
+		return 0xffff
+	}
+
+	return uint16(f*0xffff + 0.5)
+}
+
+// DecodeHDR read a Radiance rgbe (.hdr) image. Alpha is 1.
+func DecodeHDR(r io.Reader) (*HDRImage, error) {
+	br := bufio.NewReader(r)
+	width, height, flip, err := readHDRHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := NewHDRImage(image.Rect(0, 0, width, height))
+	scanline := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		if err = readHDRScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("hdr: scanline %v: %v", y, err)
+		}
+
+		row := y
+		if flip {
+			row = height - 1 - y
+		}
+
+		pix := img.Pix[row*img.Stride:]
+		for x := 0; x < width; x++ {
+			rgbe := scanline[4*x : 4*x+4]
+			r, g, b := rgbeToFloat(rgbe)
+			pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3] = r, g, b, 1
+		}
+	}
+
+	return img, nil
+}
+
+func DecodeHDRImage(r io.Reader) (image.Image, error) {
+	return DecodeHDR(r)
+}
+
+func DecodeHDRConfig(r io.Reader) (image.Config, error) {
+	width, height, _, err := readHDRHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: color.NRGBA64Model, Width: width, Height: height}, nil
+}
+
+// readHDRHeader read the header and resolution line, flip when rows are stored bottom up
+func readHDRHeader(r *bufio.Reader) (int, int, bool, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if !strings.HasPrefix(line, "#?") {
+		return 0, 0, false, errors.New("hdr: not a radiance file")
+	}
+
+	for {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		if format := strings.TrimPrefix(line, "FORMAT="); format != line && format != "32-bit_rle_rgbe" {
+			return 0, 0, false, fmt.Errorf("hdr: unsupported format %v", format)
+		}
+	}
+
+	line, err = r.ReadString('\n')
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var ySign, xSign string
+	var width, height int
+	if _, err = fmt.Sscanf(line, "%1sY %d %1sX %d", &ySign, &height, &xSign, &width); err != nil {
+		return 0, 0, false, fmt.Errorf("hdr: unsupported resolution %q", strings.TrimSpace(line))
+	}
+
+	if xSign != "+" || width <= 0 || height <= 0 {
+		return 0, 0, false, fmt.Errorf("hdr: unsupported resolution %q", strings.TrimSpace(line))
+	}
+
+	return width, height, ySign == "+", nil
+}
+
+// readHDRScanline read one scanline of rgbe pixels, run length encoded or not
+func readHDRScanline(r *bufio.Reader, scanline []byte) error {
+	width := len(scanline) / 4
+	header, err := r.Peek(4)
+	if err != nil {
+		return err
+	}
+
+	if width < 8 || width > 0x7fff || header[0] != 2 || header[1] != 2 || header[2]&0x80 != 0 {
+		return readHDRFlat(r, scanline)
+	}
+
+	if int(header[2])<<8|int(header[3]) != width {
+		return errors.New("scanline width mismatch")
+	}
+
+	r.Discard(4)
+
+	// each channel is run length encoded in turn
+	channel := make([]byte, width)
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if count > 128 {
+				n := int(count) - 128
+				value, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+
+				if x+n > width {
+					return errors.New("run overflows scanline")
+				}
+
+				for i := 0; i < n; i++ {
+					channel[x+i] = value
+				}
+
+				x += n
+				continue
+			}
+
+			n := int(count)
+			if n == 0 || x+n > width {
+				return errors.New("bad run length")
+			}
+
+			if _, err = io.ReadFull(r, channel[x:x+n]); err != nil {
+				return err
+			}
+
+			x += n
+		}
+
+		for x := 0; x < width; x++ {
+			scanline[4*x+c] = channel[x]
+		}
+	}
+
+	return nil
+}
+
+// readHDRFlat read uncompressed pixels, or the old run length encoding of repeated pixels
+func readHDRFlat(r *bufio.Reader, scanline []byte) error {
+	shift := uint(0)
+	for x := 0; x < len(scanline)/4; {
+		pixel := scanline[4*x : 4*x+4]
+		if _, err := io.ReadFull(r, pixel); err != nil {
+			return err
+		}
+
+		if pixel[0] == 1 && pixel[1] == 1 && pixel[2] == 1 && x > 0 {
+			n := int(pixel[3]) << shift
+			if x+n > len(scanline)/4 {
+				return errors.New("run overflows scanline")
+			}
+
+			prev := scanline[4*(x-1) : 4*x]
+			for i := 0; i < n; i++ {
+				copy(scanline[4*(x+i):], prev)
+			}
+
+			x += n
+			shift += 8
+			continue
+		}
+
+		shift = 0
+		x++
+	}
+
+	return nil
+}
+
+func rgbeToFloat(rgbe []byte) (float32, float32, float32) {
+	if rgbe[3] == 0 {
+		return 0, 0, 0
+	}
+
+	f := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+	return float32(rgbe[0]) * f, float32(rgbe[1]) * f, float32(rgbe[2]) * f
+}
+
+func floatToRGBE(r, g, b float32) [4]byte {
+	v := r
+	if g > v {
+		v = g
+	}
+
+	if b > v {
+		v = b
+	}
+
+	if v < 1e-32 {
+		return [4]byte{}
+	}
+
+	m, e := math.Frexp(float64(v))
+	scale := m * 256 / float64(v)
+	channel := func(c float32) byte {
+		if c <= 0 {
+			return 0
+		}
+
+		return byte(float64(c) * scale)
+	}
+
+	return [4]byte{channel(r), channel(g), channel(b), byte(e + 128)}
+}
+
+// EncodeHDR write img as a run length encoded Radiance rgbe (.hdr) image, alpha is dropped
+func EncodeHDR(w io.Writer, img *HDRImage) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %v +X %v\n", height, width)
+
+	scanline := make([]byte, 4*width)
+	channel := make([]byte, width)
+	for y := 0; y < height; y++ {
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			rgbe := floatToRGBE(pix[4*x], pix[4*x+1], pix[4*x+2])
+			copy(scanline[4*x:], rgbe[:])
+		}
+
+		if width < 8 || width > 0x7fff {
+			bw.Write(scanline)
+			continue
+		}
+
+		bw.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+		for c := 0; c < 4; c++ {
+			for x := 0; x < width; x++ {
+				channel[x] = scanline[4*x+c]
+			}
+
+			writeHDRRuns(bw, channel)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeHDRRuns run length encode a channel, runs shorter than 4 are written as literals
+func writeHDRRuns(w *bufio.Writer, data []byte) {
+	for i := 0; i < len(data); {
+		// find the next run
+		start, run := i, 0
+		for ; start < len(data); start++ {
+			run = 1
+			for start+run < len(data) && run < 127 && data[start+run] == data[start] {
+				run++
+			}
+
+			if run >= 4 {
+				break
+			}
+		}
+
+		// literals before it
+		for i < start {
+			n := start - i
+			if n > 128 {
+				n = 128
+			}
+
+			w.WriteByte(byte(n))
+			w.Write(data[i : i+n])
+			i += n
+		}
+
+		if start < len(data) {
+			w.WriteByte(byte(128 + run))
+			w.WriteByte(data[start])
+			i = start + run
+		}
+	}
+}
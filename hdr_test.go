@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+// testHDRImage a gradient over several orders of magnitude with a run of equal pixels in each row
+func testHDRImage(width, height int) *HDRImage {
+	img := NewHDRImage(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := float32(math.Pow(10, float64(x+y)/4-2))
+			if x >= width/2 {
+				v = 3
+			}
+
+			img.SetFloat(x, y, [4]float32{v, v / 2, float32(y) / float32(height), float32(x) / float32(width)})
+		}
+	}
+
+	return img
+}
+
+func TestHDRRoundTrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+	}{
+		// scanlines narrower than 8 aren't run length encoded
+		{"flat", 5, 3},
+		{"run length encoded", 40, 6},
+		{"single pixel", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := testHDRImage(tt.width, tt.height)
+			var buf bytes.Buffer
+			if err := EncodeHDR(&buf, want); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, format, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := decoded.(*HDRImage)
+			if format != "hdr" || !ok {
+				t.Fatalf("decoded %T as %v", decoded, format)
+			}
+
+			if got.Rect != want.Rect {
+				t.Fatalf("bounds %v, want %v", got.Rect, want.Rect)
+			}
+
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					g, w := got.FloatAt(x, y), want.FloatAt(x, y)
+					// rgbe keeps 8 bits of the largest channel, alpha is dropped
+					tolerance := math.Max(float64(w[0]), float64(w[2])) / 128
+					for c := 0; c < 3; c++ {
+						if math.Abs(float64(g[c]-w[c])) > tolerance {
+							t.Fatalf("pixel %v,%v is %v, want %v", x, y, g, w)
+						}
+					}
+
+					if g[3] != 1 {
+						t.Fatalf("pixel %v,%v alpha %v, want 1", x, y, g[3])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+//go:build linux && egl
+// +build linux,egl
+
+package headless
+
+/*
+#cgo LDFLAGS: -lEGL
+#include <stdlib.h>
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+// prefer the surfaceless mesa platform, falls back to the default display
+EGLDisplay GetSurfacelessDisplay() {
+  PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+    (PFNEGLGETPLATFORMDISPLAYEXTPROC) eglGetProcAddress("eglGetPlatformDisplayEXT");
+
+  if (getPlatformDisplay != NULL) {
+    EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+    if (display != EGL_NO_DISPLAY) {
+      return display;
+    }
+  }
+
+  return eglGetDisplay(EGL_DEFAULT_DISPLAY);
+}
+
+EGLContext CreateCoreContext(EGLDisplay display, int major, int minor) {
+  EGLint configAttribs[] = {
+    EGL_SURFACE_TYPE, EGL_PBUFFER_BIT,
+    EGL_RED_SIZE, 8,
+    EGL_GREEN_SIZE, 8,
+    EGL_BLUE_SIZE, 8,
+    EGL_ALPHA_SIZE, 8,
+    EGL_DEPTH_SIZE, 24,
+    EGL_RENDERABLE_TYPE, EGL_OPENGL_BIT,
+    EGL_NONE
+  };
+
+  EGLConfig config;
+  EGLint numConfigs = 0;
+  if (!eglChooseConfig(display, configAttribs, &config, 1, &numConfigs) || numConfigs == 0) {
+    // surfaceless displays may not expose pbuffer configs
+    configAttribs[1] = 0;
+    if (!eglChooseConfig(display, configAttribs, &config, 1, &numConfigs) || numConfigs == 0) {
+      return EGL_NO_CONTEXT;
+    }
+  }
+
+  EGLint contextAttribs[] = {
+    EGL_CONTEXT_MAJOR_VERSION, major,
+    EGL_CONTEXT_MINOR_VERSION, minor,
+    EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+    EGL_NONE
+  };
+
+  return eglCreateContext(display, config, EGL_NO_CONTEXT, contextAttribs);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Context an OpenGL context with no window or default framebuffer
+type Context struct {
+	display C.EGLDisplay
+	context C.EGLContext
+}
+
+// NewContext create a surfaceless OpenGL 4.1 core context and make it current.
+// Works with any EGL driver supporting EGL_KHR_surfaceless_context, including llvmpipe.
+func NewContext() (*Context, error) {
+	runtime.LockOSThread()
+
+	display := C.GetSurfacelessDisplay()
+	if display == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+		return nil, fmt.Errorf("headless.NewContext: no EGL display")
+	}
+
+	var major, minor C.EGLint
+	if C.eglInitialize(display, &major, &minor) == C.EGL_FALSE {
+		return nil, fmt.Errorf("headless.NewContext: eglInitialize failed: 0x%x", C.eglGetError())
+	}
+
+	if C.eglBindAPI(C.EGL_OPENGL_API) == C.EGL_FALSE {
+		C.eglTerminate(display)
+		return nil, fmt.Errorf("headless.NewContext: eglBindAPI failed: 0x%x", C.eglGetError())
+	}
+
+	context := C.CreateCoreContext(display, 4, 1)
+	if context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		C.eglTerminate(display)
+		return nil, fmt.Errorf("headless.NewContext: eglCreateContext failed: 0x%x", C.eglGetError())
+	}
+
+	noSurface := C.EGLSurface(C.EGL_NO_SURFACE)
+	if C.eglMakeCurrent(display, noSurface, noSurface, context) == C.EGL_FALSE {
+		C.eglDestroyContext(display, context)
+		C.eglTerminate(display)
+		return nil, fmt.Errorf("headless.NewContext: eglMakeCurrent failed: 0x%x", C.eglGetError())
+	}
+
+	return &Context{display: display, context: context}, nil
+}
+
+func (self *Context) Destroy() {
+	noSurface := C.EGLSurface(C.EGL_NO_SURFACE)
+	C.eglMakeCurrent(self.display, noSurface, noSurface, C.EGLContext(C.EGL_NO_CONTEXT))
+	C.eglDestroyContext(self.display, self.context)
+	C.eglTerminate(self.display)
+}
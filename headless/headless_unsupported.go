@@ -0,0 +1,14 @@
+//go:build !linux || !egl
+// +build !linux !egl
+
+package headless
+
+import "errors"
+
+type Context struct{}
+
+func NewContext() (*Context, error) {
+	return nil, errors.New("headless.NewContext: requires linux and building with -tags egl")
+}
+
+func (self *Context) Destroy() {}
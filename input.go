@@ -84,13 +84,13 @@ func (self *KeyPressDetection) HandleKeyPress(key glfw.Key, action glfw.Action,
 }
 
 type MouseDelta struct {
-	window               *glfw.Window
+	window               Surface
 	previousX, previousY float64
 	scale                float64
 	screenReentryTicks   int
 }
 
-func NewMouseDelta(w *glfw.Window, scale float64) *MouseDelta {
+func NewMouseDelta(w Surface, scale float64) *MouseDelta {
 	return &MouseDelta{
 		window:             w,
 		previousX:          0,
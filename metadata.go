@@ -0,0 +1,367 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"reflect"
+)
+
+// CaptureMetadataKeyword iTXt keyword captures store their metadata under
+const CaptureMetadataKeyword = "gogl:state"
+
+const (
+	// captureCompressSize metadata larger than this is zlib compressed
+	captureCompressSize = 1024
+	// maxCaptureMetadataSize largest metadata read back, compressed or not
+	maxCaptureMetadataSize = 16 << 20
+	// maxPNGChunkSize largest chunk length the png spec allows
+	maxPNGChunkSize = 0x7fffffff
+)
+
+// Params program supplied values needed to reproduce a frame.
+// Values round trip through json so numbers come back as float64.
+type Params map[string]interface{}
+
+// ParamProgram is implemented by programs that can save and restore their state
+type ParamProgram interface {
+	Params() Params
+	SetParams(params Params) error
+}
+
+// CaptureMetadata describes the program state a capture was taken in
+type CaptureMetadata struct {
+	Program string  `json:"program"`
+	Frame   int     `json:"frame"`
+	Time    float64 `json:"time"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	Params  Params  `json:"params,omitempty"`
+}
+
+// NewCaptureMetadata describe the current state of the renderer and its program
+func NewCaptureMetadata(r *Renderer) CaptureMetadata {
+	w, h := r.Surface.GetFramebufferSize()
+	meta := CaptureMetadata{
+		Program: ProgramName(r.Program),
+		Width:   w,
+		Height:  h,
+	}
+
+	if r.Clock != nil {
+		meta.Frame = r.Clock.Frame
+		meta.Time = r.Clock.Time()
+	}
+
+	if p, ok := r.Program.(ParamProgram); ok {
+		meta.Params = p.Params()
+	}
+
+	return meta
+}
+
+// ProgramName type name of a program, e.g. MandelbrotProgram
+func ProgramName(program Program) string {
+	t := reflect.TypeOf(program)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
+
+// Float set v from a number param, leaves v alone when missing
+func (self Params) Float(key string, v *float64) error {
+	x, ok := self[key]
+	if !ok {
+		return nil
+	}
+
+	f, ok := x.(float64)
+	if !ok {
+		return fmt.Errorf("param %v is not a number", key)
+	}
+
+	*v = f
+	return nil
+}
+
+func (self Params) Int(key string, v *int) error {
+	f := float64(*v)
+	if err := self.Float(key, &f); err != nil {
+		return err
+	}
+
+	*v = int(f)
+	return nil
+}
+
+func (self Params) Int32(key string, v *int32) error {
+	f := float64(*v)
+	if err := self.Float(key, &f); err != nil {
+		return err
+	}
+
+	*v = int32(f)
+	return nil
+}
+
+func (self Params) Bool(key string, v *bool) error {
+	x, ok := self[key]
+	if !ok {
+		return nil
+	}
+
+	b, ok := x.(bool)
+	if !ok {
+		return fmt.Errorf("param %v is not a bool", key)
+	}
+
+	*v = b
+	return nil
+}
+
+// EncodePNG write img as a png with the metadata in an iTXt chunk,
+// the program name is also written to a tEXt Title chunk for image viewers
+func EncodePNG(w io.Writer, img image.Image, meta CaptureMetadata) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	// signature and IHDR, text chunks follow
+	data := buf.Bytes()
+	header := 8 + 12 + int(binary.BigEndian.Uint32(data[8:]))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(data[:header])
+	if err := writeCaptureMetadata(bw, meta); err != nil {
+		return err
+	}
+
+	bw.Write(data[header:])
+	return bw.Flush()
+}
+
+// writeCaptureMetadata write the text chunks holding meta
+func writeCaptureMetadata(w io.Writer, meta CaptureMetadata) error {
+	state, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	writePNGChunk(w, "tEXt", bytes.Join([][]byte{
+		[]byte("Title"), []byte(meta.Program),
+	}, []byte{0}))
+
+	// keyword, compression flag and method, no language or translated keyword
+	itxt := append([]byte(CaptureMetadataKeyword), 0, 0, 0, 0, 0)
+	if len(state) > captureCompressSize {
+		itxt[len(CaptureMetadataKeyword)+1] = 1
+
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(state)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		state = buf.Bytes()
+	}
+
+	writePNGChunk(w, "iTXt", append(itxt, state...))
+	return nil
+}
+
+func writePNGChunk(w io.Writer, name string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc.Sum32())
+
+	w.Write(header)
+	w.Write(data)
+	w.Write(sum)
+}
+
+// ReadCaptureMetadata read capture metadata from a png written by EncodePNG
+func ReadCaptureMetadata(r io.Reader) (*CaptureMetadata, error) {
+	sig := make([]byte, 8)
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return nil, err
+	}
+
+	if string(sig) != "\x89PNG\r\n\x1a\n" {
+		return nil, errors.New("not a png")
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+
+		length := binary.BigEndian.Uint32(header)
+		name := string(header[4:])
+		if name == "IDAT" || name == "IEND" {
+			// text chunks are written before the image data
+			break
+		}
+
+		if length > maxPNGChunkSize {
+			return nil, fmt.Errorf("png %v chunk length %v is too long", name, length)
+		}
+
+		n := int(length)
+		if (name != "tEXt" && name != "iTXt") || n > maxCaptureMetadataSize {
+			// skip without reading into memory, and the crc
+			if _, err := io.CopyN(io.Discard, r, int64(n)+4); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data := make([]byte, n+4)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		data = data[:n]
+		var text []byte
+		switch name {
+		case "tEXt":
+			text = parsePNGText(data)
+		case "iTXt":
+			text = parsePNGInternationalText(data)
+		}
+
+		if text == nil {
+			continue
+		}
+
+		meta := &CaptureMetadata{}
+		if err := json.Unmarshal(text, meta); err != nil {
+			return nil, err
+		}
+
+		return meta, nil
+	}
+
+	return nil, errors.New("png has no capture metadata")
+}
+
+// parsePNGText value of a tEXt chunk holding capture metadata, nil otherwise
+func parsePNGText(data []byte) []byte {
+	keyword, text, ok := bytes.Cut(data, []byte{0})
+	if !ok || string(keyword) != CaptureMetadataKeyword {
+		return nil
+	}
+
+	return text
+}
+
+// parsePNGInternationalText value of an iTXt chunk holding capture metadata, nil otherwise
+func parsePNGInternationalText(data []byte) []byte {
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok || string(keyword) != CaptureMetadataKeyword || len(rest) < 2 {
+		return nil
+	}
+
+	compressed := rest[0] == 1
+	// skip language tag and translated keyword
+	_, rest, _ = bytes.Cut(rest[2:], []byte{0})
+	_, text, ok := bytes.Cut(rest, []byte{0})
+	if !ok {
+		return nil
+	}
+
+	if !compressed {
+		return text
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(text))
+	if err != nil {
+		return nil
+	}
+
+	text, err = io.ReadAll(io.LimitReader(zr, maxCaptureMetadataSize+1))
+	if err != nil || len(text) > maxCaptureMetadataSize {
+		return nil
+	}
+
+	return text
+}
+
+// LoadCaptureMetadata read capture metadata from a png file
+func LoadCaptureMetadata(name string) (*CaptureMetadata, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadCaptureMetadata(bufio.NewReader(f))
+}
+
+// Restore return the program to the state a capture was taken in
+func (self *Renderer) Restore(name string) error {
+	meta, err := LoadCaptureMetadata(name)
+	if err != nil {
+		return err
+	}
+
+	if program := ProgramName(self.Program); meta.Program != program {
+		return fmt.Errorf("capture is of %v, not %v", meta.Program, program)
+	}
+
+	p, ok := self.Program.(ParamProgram)
+	if !ok {
+		return fmt.Errorf("%v can not restore params", meta.Program)
+	}
+
+	self.restoreSize(meta.Width, meta.Height)
+
+	if err = p.SetParams(meta.Params); err != nil {
+		return err
+	}
+
+	if self.Clock != nil {
+		self.Clock.Seek(meta.Frame, meta.Time)
+	}
+
+	fmt.Println("Restored", name)
+	return nil
+}
+
+// restoreSize resize the window to the size a capture was taken at, views depending on the
+// aspect ratio differ otherwise. Headless surfaces keep their size.
+func (self *Renderer) restoreSize(width, height int) {
+	w, h := self.Surface.GetFramebufferSize()
+	if width <= 0 || height <= 0 || (width == w && height == h) {
+		return
+	}
+
+	if self.Window == nil {
+		fmt.Printf("capture is %vx%v, rendering at %vx%v shows a different view\n", width, height, w, h)
+		return
+	}
+
+	// window size is in screen coordinates, which can differ from pixels
+	sw, sh := self.Window.GetSize()
+	self.Window.SetSize(width*sw/w, height*sh/h)
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pngWithChunks a 1x1 png with raw chunks inserted after IHDR
+func pngWithChunks(t *testing.T, chunks ...[]byte) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	header := 8 + 12 + int(binary.BigEndian.Uint32(data[8:]))
+	out := append([]byte{}, data[:header]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+
+	return append(out, data[header:]...)
+}
+
+func pngChunk(name string, data []byte) []byte {
+	var buf bytes.Buffer
+	writePNGChunk(&buf, name, data)
+	return buf.Bytes()
+}
+
+func TestCaptureMetadata(t *testing.T) {
+	meta := CaptureMetadata{
+		Program: "MandelbrotProgram",
+		Frame:   12,
+		Time:    0.2,
+		Width:   640,
+		Height:  480,
+		Params:  Params{"zoom": 2.5, "center": []interface{}{-0.5, 0.1}, "smooth": true},
+	}
+
+	large := meta
+	large.Params = Params{"notes": strings.Repeat("a long param ", 200)}
+
+	encode := func(meta CaptureMetadata) []byte {
+		var buf bytes.Buffer
+		if err := EncodePNG(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3)), meta); err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.Bytes()
+	}
+
+	state, _ := json.Marshal(meta)
+	plain := encode(meta)
+
+	tests := []struct {
+		name string
+		data []byte
+		want *CaptureMetadata
+		// iTXt compression flag written
+		compressed bool
+		err        bool
+	}{
+		{name: "plain itxt", data: plain, want: &meta},
+		{name: "compressed itxt", data: encode(large), want: &large, compressed: true},
+		{name: "text only", data: pngWithChunks(t, pngChunk("tEXt", append([]byte(CaptureMetadataKeyword+"\x00"), state...))), want: &meta},
+		{name: "no metadata", data: pngWithChunks(t, pngChunk("tEXt", []byte("Title\x00a"))), err: true},
+		{name: "truncated", data: plain[:bytes.Index(plain, []byte("iTXt"))+20], err: true},
+		{name: "not a png", data: []byte("GIF89a"), err: true},
+		// lengths that wrapped a uint32 when the crc was added
+		{name: "wrapping length", data: pngWithChunks(t, []byte{0xff, 0xff, 0xff, 0xfc, 't', 'E', 'X', 't'}), err: true},
+		{name: "length past the spec", data: pngWithChunks(t, []byte{0x80, 0, 0, 0, 'i', 'T', 'X', 't'}), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCaptureMetadata(bytes.NewReader(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Errorf("read %+v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// params come back through json
+			want := *tt.want
+			params, _ := json.Marshal(want.Params)
+			json.Unmarshal(params, &want.Params)
+			if !reflect.DeepEqual(got, &want) {
+				t.Errorf("read %+v, want %+v", got, want)
+			}
+
+			_, chunks := pngChunks(t, tt.data)
+			for _, itxt := range chunks["iTXt"] {
+				if compressed := itxt[len(CaptureMetadataKeyword)+1] == 1; compressed != tt.compressed {
+					t.Errorf("iTXt compressed %v, want %v", compressed, tt.compressed)
+				}
+			}
+
+			if _, err := png.Decode(bytes.NewReader(tt.data)); err != nil {
+				t.Errorf("image no longer decodes: %v", err)
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ import (
 
 type ModelBufferObject struct {
 	vao, vbo, ibo uint32
+	indexType     uint32
+	indices       int32
 	*Model
 }
 
@@ -22,63 +24,38 @@ func NewModelBufferObject(file string) *ModelBufferObject {
 	log.Printf(
 		"loaded %v with %v vertices and %v vertex indices\n",
 		file,
-		len(model.Vecs),
+		len(model.Vecs)/3,
 		len(model.VecIndices),
 	)
 
-	var vao, vbo, ibo uint32
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-
-	// Vertices
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	vecSize := len(model.Vecs) * F32_SIZE
-	normalSize := len(model.Normals) * F32_SIZE
-	uvSize := len(model.Uvs) * F32_SIZE
-	gl.BufferData(gl.ARRAY_BUFFER, vecSize+uvSize+normalSize, nil, gl.STATIC_DRAW)
-	gl.BufferSubData(gl.ARRAY_BUFFER, 0, vecSize, gl.Ptr(model.Vecs))
-
-	if normalSize != 0 {
-		gl.BufferSubData(gl.ARRAY_BUFFER, vecSize, normalSize, gl.Ptr(model.Normals))
-	}
-
-	if uvSize != 0 {
-		gl.BufferSubData(gl.ARRAY_BUFFER, vecSize+normalSize, uvSize, gl.Ptr(model.Uvs))
-	}
-
-	// Indices
-	gl.GenBuffers(1, &ibo)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ibo)
-	vecISize := len(model.VecIndices) * F32_SIZE
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, vecISize, gl.Ptr(model.VecIndices), gl.STATIC_DRAW)
-
-	// bind positions
-	gl.EnableVertexAttribArray(0)
-	gl.VertexAttribPointerWithOffset(0, 3, gl.FLOAT, true, 0, uintptr(0))
+	vertices, indices, layout := model.Mesh()
 
-	// bind normals
-	if normalSize != 0 {
-		gl.EnableVertexAttribArray(2)
-		gl.VertexAttribPointerWithOffset(2, 3, gl.FLOAT, false, 0, uintptr(vecSize))
+	defer State.Push().Pop()
+	vao, vbo, count, err := newVertexArray(vertices, layout, gl.STATIC_DRAW)
+	if err != nil {
+		panic(fmt.Sprintf("%v: %v", file, err))
 	}
 
-	// bind uvs
-	if vecSize != 0 {
-		gl.EnableVertexAttribArray(1)
-		gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, 0, uintptr(vecSize+normalSize))
+	ibo, typ, err := newIndexBuffer(indices, count)
+	if err != nil {
+		panic(fmt.Sprintf("%v: %v", file, err))
 	}
 
 	return &ModelBufferObject{
 		vao, vbo, ibo,
+		typ,
+		indices.Size(),
 		model,
 	}
 }
 
 func (self ModelBufferObject) Draw() {
-	gl.BindVertexArray(self.vao)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, self.ibo)
-	gl.DrawElements(gl.TRIANGLES, int32(len(self.VecIndices)*4), gl.UNSIGNED_INT, nil)
+	self.DrawRange(DrawRange{Count: self.indices})
+}
+
+func (self ModelBufferObject) DrawRange(r DrawRange) {
+	State.BindVertexArray(self.vao)
+	drawElements(gl.TRIANGLES, self.indexType, self.indices, r)
 }
 
 func (self ModelBufferObject) VAO() uint32 {
@@ -106,6 +83,69 @@ type Model struct {
 	UvIndices     []uint32
 }
 
+// Mesh interleave positions with the texture coordinates and normals the model has, one
+// vertex per distinct combination of indices used by a face. Missing ones are zero.
+func (self *Model) Mesh() (Vertices, Indices, VertexLayout) {
+	hasUvs, hasNormals := len(self.Uvs) > 0, len(self.Normals) > 0
+	components := []int32{3}
+	if hasUvs {
+		components = append(components, 2)
+	}
+
+	if hasNormals {
+		components = append(components, 3)
+	}
+
+	layout := InterleavedLayout(components...)
+	if !hasUvs && hasNormals {
+		// normals stay at location 2
+		layout[1].Location = 2
+	}
+
+	// lookup copies count floats at index i of values, zeros past the end
+	lookup := func(values []float32, i uint32, count int) []float32 {
+		start := int(i) * count
+		if start+count > len(values) {
+			return make([]float32, count)
+		}
+
+		return values[start : start+count]
+	}
+
+	vertices := Vertices{}
+	indices := make(Indices, len(self.VecIndices))
+	seen := map[[3]uint32]uint32{}
+	for i, v := range self.VecIndices {
+		key := [3]uint32{v}
+		if i < len(self.UvIndices) {
+			key[1] = self.UvIndices[i]
+		}
+
+		if i < len(self.NormalIndices) {
+			key[2] = self.NormalIndices[i]
+		}
+
+		index, ok := seen[key]
+		if !ok {
+			index = uint32(len(seen))
+			seen[key] = index
+
+			vertices = append(vertices, lookup(self.Vecs, key[0], 3)...)
+			if hasUvs {
+				vertices = append(vertices, lookup(self.Uvs, key[1], 2)...)
+			}
+
+			if hasNormals {
+				vertices = append(vertices, lookup(self.Normals, key[2], 3)...)
+			}
+		}
+
+		indices[i] = index
+	}
+
+	return vertices, indices, layout
+}
+
 // NewModel will read an OBJ model file and create a Model from its contents
 func NewModel(file string) *Model {
 	// Open the file for reading and check for errors.
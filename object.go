@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"fmt"
+	"log"
 	"unsafe"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
@@ -10,87 +12,199 @@ var F32_SIZE int = int(unsafe.Sizeof(float32(0)))
 var F32_SIZE32 int32 = int32(F32_SIZE)
 
 type VertexBuffer interface {
-	VertexSize() int32
 	BufferSize() int
 }
 
 type Vertices []float32
 
-func (self Vertices) VertexSize() int32 {
-	return 4 * int32(F32_SIZE)
-}
-
 func (self Vertices) BufferSize() int {
 	return len(self) * F32_SIZE
 }
 
-type Indices []uint8
+// Indices of vertices, uploaded as the smallest of uint8, uint16 or uint32 that addresses every vertex
+type Indices []uint32
 
 func (self Indices) Size() int32 {
 	return int32(len(self))
 }
 
+// indexType the smallest index type that addresses count vertices
+func indexType(count int) uint32 {
+	switch {
+	case count <= 1<<8:
+		return gl.UNSIGNED_BYTE
+	case count <= 1<<16:
+		return gl.UNSIGNED_SHORT
+	}
+
+	return gl.UNSIGNED_INT
+}
+
+func indexSize(typ uint32) int {
+	switch typ {
+	case gl.UNSIGNED_BYTE:
+		return 1
+	case gl.UNSIGNED_SHORT:
+		return 2
+	}
+
+	return 4
+}
+
+// pack the indices as typ
+func (self Indices) pack(typ uint32) interface{} {
+	switch typ {
+	case gl.UNSIGNED_BYTE:
+		data := make([]uint8, len(self))
+		for i, index := range self {
+			data[i] = uint8(index)
+		}
+
+		return data
+	case gl.UNSIGNED_SHORT:
+		data := make([]uint16, len(self))
+		for i, index := range self {
+			data[i] = uint16(index)
+		}
+
+		return data
+	}
+
+	return []uint32(self)
+}
+
+// newIndexBuffer upload indices of count vertices to the bound vertex array's element buffer
+func newIndexBuffer(indices Indices, count int) (uint32, uint32, error) {
+	if len(indices) == 0 {
+		return 0, 0, fmt.Errorf("index data is empty")
+	}
+
+	for i, index := range indices {
+		if int(index) >= count {
+			return 0, 0, fmt.Errorf("index %v is vertex %v, there are %v", i, index, count)
+		}
+	}
+
+	var ibo uint32
+	typ := indexType(count)
+	gl.GenBuffers(1, &ibo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ibo)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*indexSize(typ), gl.Ptr(indices.pack(typ)), gl.STATIC_DRAW)
+
+	return ibo, typ, nil
+}
+
+// checkDrawMode error for anything but points, lines, line strips and loops, triangles, strips and fans
+func checkDrawMode(mode uint32) error {
+	switch mode {
+	case gl.POINTS, gl.LINES, gl.LINE_STRIP, gl.LINE_LOOP, gl.TRIANGLES, gl.TRIANGLE_STRIP, gl.TRIANGLE_FAN:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported draw mode 0x%x", mode)
+}
+
+// DrawRange part of a buffer object to draw, as the buffer's Mode
+type DrawRange struct {
+	// First index, or vertex of unindexed buffers, and how many to draw
+	First, Count int32
+	// BaseVertex added to every index, or to First of unindexed buffers
+	BaseVertex int32
+	// Instances drawn, one when 0
+	Instances int32
+}
+
 type BufferObject interface {
 	Draw()
+	// DrawRange draw part of the buffer, see DrawRange
+	DrawRange(r DrawRange)
 	VAO() uint32
 	VBO() uint32
 	IBO() uint32
 }
 
+// drawElements draw a range of the bound vertex array's indices of type typ
+func drawElements(mode, typ uint32, indices int32, r DrawRange) {
+	if r.First < 0 || r.Count < 0 || r.First+r.Count > indices {
+		log.Printf("DrawRange: indices %v to %v of %v\n", r.First, r.First+r.Count, indices)
+		return
+	}
+
+	offset := int(r.First) * indexSize(typ)
+	if r.Instances > 1 {
+		gl.DrawElementsInstancedBaseVertex(mode, r.Count, typ, gl.PtrOffset(offset), r.Instances, r.BaseVertex)
+	} else {
+		gl.DrawElementsBaseVertexWithOffset(mode, r.Count, typ, uintptr(offset), r.BaseVertex)
+	}
+}
+
+// VIBuffer indexed vertices
 type VIBuffer struct {
-	Tris          int32
+	Tris      int32
+	Mode      uint32
+	IndexType uint32
+	Layout    VertexLayout
+	// Count of vertices
+	Count         int
 	vao, vbo, ibo uint32
 
 	*Vertices
 	*Indices
 }
 
+// NewIndexedBuffer vertices of any layout drawn by index as mode, e.g. gl.TRIANGLES.
+// Fails when the layout does not fit the vertices or an index is past the last vertex.
+func NewIndexedBuffer(vertices Vertices, indices Indices, layout VertexLayout, mode uint32) (*VIBuffer, error) {
+	if err := checkDrawMode(mode); err != nil {
+		return nil, err
+	}
+
+	defer State.Push().Pop()
+	vao, vbo, count, err := newVertexArray(vertices, layout, gl.STATIC_DRAW)
+	if err != nil {
+		return nil, err
+	}
+
+	// the element buffer is part of the vertex array's state
+	ibo, typ, err := newIndexBuffer(indices, count)
+	if err != nil {
+		State.DeleteVertexArrays(vao)
+		gl.DeleteBuffers(1, &vbo)
+		return nil, err
+	}
+
+	return &VIBuffer{
+		Tris:      indices.Size(),
+		Mode:      mode,
+		IndexType: typ,
+		Layout:    layout,
+		Count:     count,
+		vao:       vao,
+		vbo:       vbo,
+		ibo:       ibo,
+		Vertices:  &vertices,
+		Indices:   &indices,
+	}, nil
+}
+
+// NewVIBuffer triangles of interleaved 3 position, 2 texture and 3 normal floats, see LayoutP3T2N3
 func NewVIBuffer(vertices Vertices, indices Indices, tris int32) *VIBuffer {
-	buf := &VIBuffer{Vertices: &vertices, Indices: &indices, Tris: tris}
-
-	gl.GenVertexArrays(1, &buf.vao)
-	gl.BindVertexArray(buf.vao)
-
-	// Vertices
-	gl.GenBuffers(1, &buf.vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, buf.vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, vertices.BufferSize(), gl.Ptr(vertices), gl.STATIC_DRAW)
-
-	// Indices
-	gl.GenBuffers(1, &buf.ibo)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, buf.ibo)
-	// byte size indices
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices), gl.Ptr(indices), gl.STATIC_DRAW)
-
-	gl.EnableVertexAttribArray(0)
-	gl.VertexAttribPointerWithOffset(
-		0, 3, gl.FLOAT, false,
-		int32(F32_SIZE*8),
-		uintptr(0),
-	)
-
-	// bind texture coordinates
-	gl.EnableVertexAttribArray(1)
-	gl.VertexAttribPointerWithOffset(
-		1, 2, gl.FLOAT, false,
-		int32(F32_SIZE*8),
-		uintptr(3*int32(F32_SIZE)),
-	)
-
-	// bind normals
-	gl.EnableVertexAttribArray(2)
-	gl.VertexAttribPointerWithOffset(
-		2, 3, gl.FLOAT, false,
-		int32(F32_SIZE*8),
-		uintptr(5*int32(F32_SIZE)),
-	)
+	buf, err := NewIndexedBuffer(vertices, indices, LayoutP3T2N3, gl.TRIANGLES)
+	if err != nil {
+		panic(err)
+	}
 
+	buf.Tris = tris
 	return buf
 }
 
 func (self VIBuffer) Draw() {
-	gl.BindVertexArray(self.vao)
-	gl.DrawElements(gl.TRIANGLES, self.Indices.Size(), gl.UNSIGNED_BYTE, nil)
+	self.DrawRange(DrawRange{Count: self.Indices.Size()})
+}
+
+func (self VIBuffer) DrawRange(r DrawRange) {
+	State.BindVertexArray(self.vao)
+	drawElements(self.Mode, self.IndexType, self.Indices.Size(), r)
 }
 
 func (self VIBuffer) VAO() uint32 {
@@ -105,47 +219,77 @@ func (self VIBuffer) IBO() uint32 {
 	return self.ibo
 }
 
+// VBuffer vertices drawn in order
 type VBuffer struct {
-	Tris          int32
-	Size          int32
+	Tris   int32
+	Size   int32
+	Mode   uint32
+	Layout VertexLayout
+	// Count of vertices
+	Count         int
 	vao, vbo, ibo uint32
 
 	*Vertices
 	*Indices
 }
 
+// NewVertexBuffer vertices of any layout drawn in order as mode, e.g. gl.TRIANGLE_STRIP.
+// Fails when the layout does not fit the vertices.
+func NewVertexBuffer(vertices Vertices, layout VertexLayout, mode uint32) (*VBuffer, error) {
+	if err := checkDrawMode(mode); err != nil {
+		return nil, err
+	}
+
+	defer State.Push().Pop()
+	vao, vbo, count, err := newVertexArray(vertices, layout, gl.STATIC_DRAW)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VBuffer{
+		Tris:     int32(count),
+		Count:    count,
+		Size:     layout[0].Components,
+		Mode:     mode,
+		Layout:   layout,
+		vao:      vao,
+		vbo:      vbo,
+		Vertices: &vertices,
+	}, nil
+}
+
+// NewV4Buffer a triangle strip of tris vertices, each a position and texture coordinates of size floats
 func NewV4Buffer(vertices Vertices, size int32, tris int32) *VBuffer {
-	buf := &VBuffer{Vertices: &vertices, Size: size, Tris: tris}
-
-	gl.GenVertexArrays(1, &buf.vao)
-	gl.BindVertexArray(buf.vao)
-
-	gl.GenBuffers(1, &buf.vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, buf.vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, vertices.BufferSize(), gl.Ptr(vertices), gl.STATIC_DRAW)
-
-	// bind positions
-	gl.EnableVertexAttribArray(0)
-	gl.VertexAttribPointerWithOffset(
-		0, size, gl.FLOAT, false,
-		vertices.VertexSize(),
-		uintptr(0),
-	)
-
-	// bind textures
-	gl.EnableVertexAttribArray(1)
-	gl.VertexAttribPointerWithOffset(
-		1, size, gl.FLOAT, false,
-		vertices.VertexSize(),
-		uintptr(size*int32(F32_SIZE)),
-	)
+	buf, err := NewVertexBuffer(vertices, InterleavedLayout(size, size), gl.TRIANGLE_STRIP)
+	if err != nil {
+		panic(err)
+	}
 
+	if tris > buf.Tris {
+		panic(fmt.Sprintf("NewV4Buffer: drawing %v vertices of %v", tris, buf.Tris))
+	}
+
+	buf.Tris = tris
 	return buf
 }
 
 func (self VBuffer) Draw() {
-	gl.BindVertexArray(self.vao)
-	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, self.Tris)
+	self.DrawRange(DrawRange{Count: self.Tris})
+}
+
+func (self VBuffer) DrawRange(r DrawRange) {
+	first := r.First + r.BaseVertex
+	if first < 0 || r.Count < 0 || int(first+r.Count) > self.Count {
+		log.Printf("DrawRange: vertices %v to %v of %v\n", first, first+r.Count, self.Count)
+		return
+	}
+
+	State.BindVertexArray(self.vao)
+	if r.Instances > 1 {
+		gl.DrawArraysInstanced(self.Mode, first, r.Count, r.Instances)
+	} else {
+		gl.DrawArrays(self.Mode, first, r.Count)
+	}
 }
 
 func (self VBuffer) VAO() uint32 {
@@ -160,12 +304,14 @@ func (self VBuffer) IBO() uint32 {
 	return 0
 }
 
+// 2 Position / 2 Texture, see LayoutP2T2
 var TriangleVertices = Vertices{
 	-0.8, -0.8, 0.0, 0.0,
 	0.0, 0.8, 0.0, 0.5,
 	0.8, -0.8, 1.0, 0.0,
 }
 
+// 2 Position / 2 Texture, see LayoutP2T2
 var QuadVertices = Vertices{
 	-1.0, 1.0, 0.0, 1.0,
 	1.0, 1.0, 1.0, 1.0,
@@ -239,6 +385,7 @@ var C1AltIndices = Indices{
 	0, 7, 11,
 }
 
+// 4 Position / 2 Texture / 2 unused, see LayoutP4T2
 var CubeVertices = Vertices{
 	-.5, -.5, .5, 1, 0, 0, 0, 0,
 	-.5, .5, .5, 1, 1, 0, 0, 0,
@@ -259,7 +406,7 @@ var CubeIndices = Indices{
 	7, 5, 6, 7, 4, 5,
 }
 
-// 3 Position / 2 Texture / 3 Normal
+// 3 Position / 2 Texture / 3 Normal, see LayoutP3T2N3
 var CubeAltVertices = Vertices{
 	// Front face
 	-0.5, -0.5, 0.5,
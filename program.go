@@ -5,7 +5,13 @@ import "github.com/go-gl/glfw/v3.3/glfw"
 type Program interface {
 	Render(t float64)
 	LoadR(*Renderer)
-	Load(window *glfw.Window)
+	Load(surface Surface)
 	ResizeCallback(w *glfw.Window, width int, height int)
 	KeyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey)
 }
+
+// PausedProgram is implemented by programs with work to do while the clock is paused,
+// such as recoloring or reloading shaders. Returns whether a frame was drawn.
+type PausedProgram interface {
+	RenderPaused(t float64) bool
+}
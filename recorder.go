@@ -1,51 +1,165 @@
 package engine
 
 import (
-	"bytes"
 	"fmt"
 	"image"
-	"image/color"
-	"image/gif"
-	"image/jpeg"
-	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ericpauley/go-quantize/quantize"
-	"github.com/gen2brain/beeep"
 	"github.com/go-gl/gl/v4.1-core/gl"
-	"github.com/go-gl/glfw/v3.3/glfw"
-	"github.com/icza/mjpeg"
-	"golang.org/x/image/draw"
 )
 
+// RecorderStats snapshot of a recording in progress
+type RecorderStats struct {
+	// frames waiting on the encoders and the most that may wait
+	Queued, QueueSize int
+	// bytes held by queued frames
+	QueuedBytes int64
+
+	Captured, Encoded, Dropped int64
+}
+
+func (self RecorderStats) String() string {
+	return fmt.Sprintf(
+		"REC queue %v/%v (%.1f MB) encoded %v dropped %v",
+		self.Queued, self.QueueSize, float64(self.QueuedBytes)/(1<<20), self.Encoded, self.Dropped,
+	)
+}
+
+// RecordFormat set of outputs written by a Recorder
+type RecordFormat int
+
+const (
+	RecordMJPEG RecordFormat = 1 << iota
+	RecordGIF
+	RecordAPNG
+	RecordPNGSequence
+	RecordY4M
+)
+
+type recordedFrame struct {
+	img *image.RGBA
+	// time on the clock when the frame was captured
+	time time.Duration
+}
+
+// Recorder streams captured frames to encoders on a background goroutine
 type Recorder struct {
-	On     bool
-	Window *glfw.Window
+	On      bool
+	Surface Surface
 
-	frames    []*image.RGBA
-	startTime time.Time
-	endTime   time.Time
+	// Formats outputs written on the next Start
+	Formats RecordFormat
+	// Clock frames are timestamped with, wall time when nil
+	Clock *Clock
+	// FPS frame rate written to constant rate outputs
+	FPS float64
+	// QueueSize most frames held waiting on the encoders
+	QueueSize int
+	// DropFrames drop frames when the queue is full instead of blocking the render loop
+	DropFrames bool
+
+	// recording in progress, or the last one ended
+	current *recording
+
+	// in flight encoders
+	encoding sync.WaitGroup
+}
+
+// recording state of one Start to End, owned by its encode goroutine after End so a
+// new recording never shares frames or counters with one still encoding
+type recording struct {
+	queue    chan recordedFrame
+	frames   sync.Pool
+	encoders []FrameEncoder
+	started  time.Time
+
+	frameBytes                 int64
+	captured, encoded, dropped int64
 }
 
-func NewRecorder(window *glfw.Window) *Recorder {
+func NewRecorder(surface Surface) *Recorder {
 	return &Recorder{
-		On:     false,
-		Window: window,
+		On:        false,
+		Surface:   surface,
+		Formats:   RecordMJPEG | RecordGIF,
+		FPS:       60,
+		QueueSize: 32,
 	}
 }
 
 func (self *Recorder) Start() {
+	w, h := self.Surface.GetFramebufferSize()
+
+	encoders := self.newEncoders(w, h)
+	if len(encoders) == 0 {
+		return
+	}
+
+	rec := &recording{
+		queue:      make(chan recordedFrame, self.QueueSize),
+		encoders:   encoders,
+		started:    time.Now(),
+		frameBytes: int64(w * h * 4),
+	}
+
+	rec.frames.New = func() interface{} {
+		return image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+
+	self.current = rec
+	self.encoding.Add(1)
+	go self.encode(rec)
+
 	self.On = true
+	notify("Video Recording Started", "Press F3 to end recording", "")
+}
+
+// newEncoders create an encoder for each format, outputs are named by the current time
+func (self *Recorder) newEncoders(w, h int) []FrameEncoder {
+	stamp := time.Now().Format("20060102150405")
 
-	self.frames = make([]*image.RGBA, 0)
-	self.startTime = time.Now()
+	encoders := make([]FrameEncoder, 0)
+	add := func(enc FrameEncoder, err error) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		encoders = append(encoders, enc)
+	}
+
+	if self.Formats&RecordMJPEG != 0 {
+		add(NewMJPEGEncoder("screencaptures/videos/"+stamp+".avi", w, h, self.FPS))
+	}
+
+	if self.Formats&RecordGIF != 0 {
+		add(NewGIFEncoder("screencaptures/gifs/"+stamp+".gif", w, h))
+	}
+
+	if self.Formats&RecordAPNG != 0 {
+		add(NewAPNGEncoder("screencaptures/apngs/"+stamp+".png", w, h))
+	}
+
+	if self.Formats&RecordY4M != 0 {
+		add(NewY4MEncoder("screencaptures/videos/"+stamp+".y4m", w, h, self.FPS))
+	}
+
+	if self.Formats&RecordPNGSequence != 0 {
+		add(NewPNGSequenceEncoder("screencaptures/sequences/" + stamp))
+	}
 
-	beeep.Notify("Video Recording Started", "Press F3 to end recording", "")
+	return encoders
 }
 
 func (self *Recorder) Capture() {
-	w, h := self.Window.GetFramebufferSize()
-	img := *image.NewRGBA(image.Rect(0, 0, w, h))
+	rec := self.current
+	img := rec.frames.Get().(*image.RGBA)
+
+	// resizing mid recording is not supported, keep the original size
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, ScreenFramebuffer)
 	gl.ReadPixels(
 		0, 0,
 		int32(w), int32(h),
@@ -54,114 +168,104 @@ func (self *Recorder) Capture() {
 		gl.Ptr(img.Pix),
 	)
 
-	self.frames = append(self.frames, &img)
+	atomic.AddInt64(&rec.captured, 1)
+	frame := recordedFrame{img: img, time: self.now()}
+	if !self.DropFrames {
+		// apply backpressure to the render loop
+		rec.queue <- frame
+		return
+	}
+
+	select {
+	case rec.queue <- frame:
+	default:
+		atomic.AddInt64(&rec.dropped, 1)
+		rec.frames.Put(img)
+	}
 }
 
-func (self *Recorder) End() {
-	self.On = false
-	self.endTime = time.Now()
-	beeep.Notify("Video Recording Finished", "Please wait before closing while your video is encoded", "")
+func (self *Recorder) now() time.Duration {
+	if self.Clock != nil {
+		return time.Duration(self.Clock.Time() * float64(time.Second))
+	}
 
-	// create video
-	go func(r *Recorder) {
+	return time.Since(self.current.started)
+}
 
-		// create sub-folders
-		subFolder := "videos"
-		folder := fmt.Sprintf("screencaptures/%v/", subFolder)
-		if _, err := os.Stat(folder); os.IsNotExist(err) {
-			os.MkdirAll(folder, 0700)
-		}
+func (self *Recorder) encode(rec *recording) {
+	defer self.encoding.Done()
 
-		// create file
-		framerate := r.endTime.Sub(r.startTime).Milliseconds() / int64(len(r.frames))
-		w, h := r.Window.GetFramebufferSize()
-		name := folder + time.Now().Format("20060102150405") + ".avi"
-		video, err := mjpeg.New(name, int32(w), int32(h), int32(framerate))
-		if err != nil {
-			fmt.Println(err)
-			return
+	// a frame is shown until the next one was captured,
+	// hold one back until its duration is known
+	step := time.Duration(float64(time.Second) / self.FPS)
+	write := func(frame recordedFrame, duration time.Duration) {
+		// the last frame or the clock was rewound
+		if duration <= 0 {
+			duration = step
 		}
 
-		// encode frames
-		fmt.Println("encoding frames to video")
-		for _, frame := range r.frames {
-			buf := &bytes.Buffer{}
-			err = jpeg.Encode(buf, frame, nil)
-			if err != nil {
+		for _, enc := range rec.encoders {
+			if err := enc.Encode(frame.img, duration); err != nil {
 				fmt.Println(err)
-				return
-			}
-
-			err = video.AddFrame(buf.Bytes())
-			if err != nil {
-				fmt.Println(err)
-				return
 			}
 		}
 
-		fmt.Println("video saved")
-		beeep.Notify("Video Recording Saved!", name, "")
-		err = os.Remove(name + ".idx_")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-	}(self)
-
-	// create gif
-	go func(r *Recorder) {
-		// create gif
-		var delays []int
-		var disposal []byte
-		var images = make([]*image.Paletted, 0)
-
-		var p = make([]color.Color, 0, 256)
-		var q = quantize.MedianCutQuantizer{}
-
-		for _, img := range r.frames {
-			p = q.Quantize(p, img)
-			p[0] = color.RGBA{0, 0, 0, 0}
-
-			b := img.Bounds()
-			frame := image.NewPaletted(b, p)
-			draw.Draw(frame, frame.Rect, img, b.Min, draw.Over)
-			images = append(images, frame)
-			delays = append(delays, 2)
-			d := gif.DisposalBackground
-			disposal = append(disposal, byte(d))
-		}
+		atomic.AddInt64(&rec.encoded, 1)
+		rec.frames.Put(frame.img)
+	}
 
-		out := &gif.GIF{
-			Image:           images,
-			Delay:           delays,
-			Disposal:        disposal,
-			BackgroundIndex: byte(0),
+	var held *recordedFrame
+	for frame := range rec.queue {
+		if held != nil {
+			write(*held, frame.time-held.time)
 		}
 
-		// create sub-folders
-		subFolder := "gifs"
-		folder := fmt.Sprintf("screencaptures/%v/", subFolder)
-		if _, err := os.Stat(folder); os.IsNotExist(err) {
-			os.MkdirAll(folder, 0700)
-		}
+		frame := frame
+		held = &frame
+	}
 
-		// create file
-		name := folder + time.Now().Format("20060102150405") + ".gif"
-		f, err := os.Create(name)
-		if err != nil {
+	if held != nil {
+		write(*held, 0)
+	}
+
+	for _, enc := range rec.encoders {
+		if err := enc.Close(); err != nil {
 			fmt.Println(err)
-			return
+			continue
 		}
 
-		// encode gif
-		fmt.Println("Saving GIF", name)
-		gif.EncodeAll(f, out)
+		fmt.Println("saved", enc.Name())
+		notify("Recording Saved!", enc.Name(), "")
+	}
+}
 
-		// cleanup
-		beeep.Notify("GIF Saved!", name, "")
-		fmt.Println("gif saved")
-		if err = f.Close(); err != nil {
-			fmt.Println(err)
-		}
-	}(self)
+// Stats report queue depth and memory held by the recording
+func (self *Recorder) Stats() RecorderStats {
+	rec := self.current
+	if rec == nil {
+		return RecorderStats{}
+	}
+
+	queued := len(rec.queue)
+	return RecorderStats{
+		Queued:      queued,
+		QueueSize:   cap(rec.queue),
+		QueuedBytes: int64(queued) * rec.frameBytes,
+
+		Captured: atomic.LoadInt64(&rec.captured),
+		Encoded:  atomic.LoadInt64(&rec.encoded),
+		Dropped:  atomic.LoadInt64(&rec.dropped),
+	}
+}
+
+func (self *Recorder) End() {
+	self.On = false
+	close(self.current.queue)
+
+	notify("Video Recording Finished", "Please wait before closing while your video is encoded", "")
+}
+
+// Wait block until recordings passed to End are encoded
+func (self *Recorder) Wait() {
+	self.encoding.Wait()
 }
@@ -0,0 +1,503 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"gogl/assets"
+)
+
+// GraphResource a texture render graph passes read and write
+type GraphResource struct {
+	// PingPong keep a second texture so a pass can read the previous frame while writing the next
+	PingPong bool `json:"pingpong"`
+	// Scale size relative to the graph, 1 when 0
+	Scale float64 `json:"scale"`
+	// Init starting contents: clear, black, white or random
+	Init string `json:"init"`
+	// Format texture format name such as RGBA8, RGBA32F or R32UI, see ParseTextureFormat. RGBA8 when empty
+	Format string `json:"format"`
+
+	format   TextureFormat
+	texture  *Texture
+	pingpong *PingPong
+}
+
+// Texture holding the latest contents
+func (self *GraphResource) Texture() *Texture {
+	if self.pingpong != nil {
+		return self.pingpong.Read()
+	}
+
+	return self.texture
+}
+
+// parseFormat look up Format, only color formats can be drawn to
+func (self *GraphResource) parseFormat() error {
+	if self.Format == "" {
+		self.format = FormatRGBA8
+		return nil
+	}
+
+	format, ok := ParseTextureFormat(self.Format)
+	if !ok || format.attachment() != 0 {
+		return fmt.Errorf("unsupported texture format %v", self.Format)
+	}
+
+	self.format = format
+	return nil
+}
+
+func (self *GraphResource) size(width, height int) (int, int) {
+	if self.Scale <= 0 {
+		return width, height
+	}
+
+	w, h := int(float64(width)*self.Scale), int(float64(height)*self.Scale)
+	if w < 1 {
+		w = 1
+	}
+
+	if h < 1 {
+		h = 1
+	}
+
+	return w, h
+}
+
+// RenderPass draws a full screen quad into a resource, or the screen when Output is empty
+type RenderPass struct {
+	Name string `json:"name"`
+	// Vertex and Fragment shader files, Vertex defaults to the assets vertex shader.
+	// Files are found next to the graph file, then in ShaderIncludeDirs.
+	Vertex   string `json:"vertex"`
+	Fragment string `json:"fragment"`
+
+	// Inputs sampler uniform names to the resources bound to them
+	Inputs map[string]string `json:"inputs"`
+	Output string            `json:"output"`
+	// Uniforms set before every draw, numbers and lists of numbers
+	Uniforms Params `json:"uniforms"`
+
+	// Apply set per frame uniforms from go
+	Apply func(Shader) Shader `json:"-"`
+	// Shader compiled from Vertex and Fragment when not set
+	Shader Shader `json:"-"`
+}
+
+// RenderGraph passes run in dependency order every frame, a pass reads what its inputs' writers drew this frame.
+// Reading its own output, or breaking a cycle, reads the previous frame and needs PingPong.
+type RenderGraph struct {
+	Resources map[string]*GraphResource `json:"resources"`
+	Passes    []*RenderPass             `json:"passes"`
+
+	Width, Height int `json:"-"`
+	Frame         int `json:"-"`
+	// Apply set uniforms shared by every pass, after u_time, u_frame, u_resolution, u_mouse and scale
+	Apply func(Shader) Shader `json:"-"`
+
+	surface Surface
+	order   []*RenderPass
+	quad    *VBuffer
+	fbo     *Framebuffer
+}
+
+var renderGraphUniforms = []string{"u_time", "u_frame", "u_resolution", "u_mouse", "scale"}
+
+func NewRenderGraph(surface Surface) *RenderGraph {
+	return &RenderGraph{
+		Resources: make(map[string]*GraphResource),
+		Passes:    make([]*RenderPass, 0),
+		surface:   surface,
+	}
+}
+
+// LoadRenderGraph read and build a graph described in json
+func LoadRenderGraph(name string, surface Surface) (*RenderGraph, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := NewRenderGraph(surface)
+	if err = json.Unmarshal(data, graph); err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	dir := filepath.Dir(name)
+	for _, pass := range graph.Passes {
+		vert, err := loadGraphShader(dir, pass.Vertex, assets.VertexShader)
+		if err != nil {
+			return nil, fmt.Errorf("%v: pass %v: %v", name, pass.Name, err)
+		}
+
+		frag, err := loadGraphShader(dir, pass.Fragment, "")
+		if err != nil {
+			return nil, fmt.Errorf("%v: pass %v: %v", name, pass.Name, err)
+		}
+
+		if err = pass.Shader.CompileSources(vert, frag, nil); err != nil {
+			return nil, fmt.Errorf("%v: pass %v: %w", name, pass.Name, err)
+		}
+	}
+
+	if err = graph.Build(); err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	return graph, nil
+}
+
+func loadGraphShader(dir, name, fallback string) (*ShaderSource, error) {
+	if name == "" {
+		if fallback == "" {
+			return nil, fmt.Errorf("no fragment shader")
+		}
+
+		return PreprocessShader("", fallback)
+	}
+
+	path := filepath.Join(dir, name)
+	if data, err := os.ReadFile(path); err == nil {
+		return PreprocessShader(path, string(data))
+	}
+
+	return LoadShaderSource(name)
+}
+
+// AddResource declare a texture passes can read and write
+func (self *RenderGraph) AddResource(name string, resource *GraphResource) *RenderGraph {
+	self.Resources[name] = resource
+	return self
+}
+
+// AddPass declare a pass, passes are run in dependency order then the order added
+func (self *RenderGraph) AddPass(pass *RenderPass) *RenderGraph {
+	self.Passes = append(self.Passes, pass)
+	return self
+}
+
+// Build order the passes and allocate resources at the surface size
+func (self *RenderGraph) Build() error {
+	order, err := self.sort()
+	if err != nil {
+		return err
+	}
+
+	for _, pass := range order {
+		if pass.Shader.Program == nil {
+			return fmt.Errorf("pass %v has no shader", pass.Name)
+		}
+
+		if pass.Shader.Reflection != nil {
+			pass.Shader.Reflection.Ignore(renderGraphUniforms...)
+		}
+	}
+
+	for name, resource := range self.Resources {
+		if err := resource.parseFormat(); err != nil {
+			return fmt.Errorf("resource %v: %v", name, err)
+		}
+	}
+
+	self.order = order
+	self.Width, self.Height = self.surface.GetFramebufferSize()
+	for _, resource := range self.Resources {
+		self.allocate(resource)
+	}
+
+	if self.quad == nil {
+		self.quad = NewV4Buffer(QuadVertices, 2, 4)
+		self.fbo = NewFramebuffer()
+	}
+
+	return nil
+}
+
+// sort passes so writers run before their readers. When that is a cycle, passes reading
+// a pingpong resource written by a pass declared after them read its previous frame instead.
+func (self *RenderGraph) sort() ([]*RenderPass, error) {
+	writers := make(map[string]int)
+	for i, pass := range self.Passes {
+		if pass.Output == "" {
+			continue
+		}
+
+		if _, ok := self.Resources[pass.Output]; !ok {
+			return nil, fmt.Errorf("pass %v writes unknown resource %v", pass.Name, pass.Output)
+		}
+
+		if w, ok := writers[pass.Output]; ok {
+			return nil, fmt.Errorf("passes %v and %v both write %v", self.Passes[w].Name, pass.Name, pass.Output)
+		}
+
+		writers[pass.Output] = i
+	}
+
+	for _, pass := range self.Passes {
+		for _, name := range pass.Inputs {
+			resource, ok := self.Resources[name]
+			if !ok {
+				return nil, fmt.Errorf("pass %v reads unknown resource %v", pass.Name, name)
+			}
+
+			if pass.Output == name && !resource.PingPong {
+				return nil, fmt.Errorf("pass %v reads its own output %v, which needs pingpong", pass.Name, name)
+			}
+		}
+	}
+
+	if order, ok := self.topological(writers, false); ok {
+		return order, nil
+	}
+
+	if order, ok := self.topological(writers, true); ok {
+		return order, nil
+	}
+
+	return nil, fmt.Errorf("render graph has a cycle, make a resource pingpong to read its previous frame")
+}
+
+// topological order of the passes, ties keep declaration order.
+// previous makes readers of pingpong resources written by later passes read the previous frame.
+func (self *RenderGraph) topological(writers map[string]int, previous bool) ([]*RenderPass, bool) {
+	edges := make([][]int, len(self.Passes))
+	indegree := make([]int, len(self.Passes))
+	for r, pass := range self.Passes {
+		for _, name := range pass.Inputs {
+			w, ok := writers[name]
+			if !ok || w == r {
+				// never written, e.g. a loaded texture, or the pass's own previous frame
+				continue
+			}
+
+			if previous && w > r && self.Resources[name].PingPong {
+				// read the previous frame before it is replaced
+				edges[r] = append(edges[r], w)
+				indegree[w]++
+				continue
+			}
+
+			edges[w] = append(edges[w], r)
+			indegree[r]++
+		}
+	}
+
+	order := make([]*RenderPass, 0, len(self.Passes))
+	done := make([]bool, len(self.Passes))
+	for len(order) < len(self.Passes) {
+		next := -1
+		for i := range self.Passes {
+			if !done[i] && indegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			return nil, false
+		}
+
+		done[next] = true
+		order = append(order, self.Passes[next])
+		for _, e := range edges[next] {
+			indegree[e]--
+		}
+	}
+
+	return order, true
+}
+
+func (self *RenderGraph) allocate(resource *GraphResource) {
+	width, height := resource.size(self.Width, self.Height)
+	switch {
+	case resource.pingpong != nil:
+		resource.pingpong.Resize(width, height)
+	case resource.texture != nil:
+		resource.texture.Resize(width, height)
+	case resource.PingPong:
+		resource.pingpong = NewPingPong(width, height, resource.format.Internal)
+		resource.pingpong.Rescale = true
+		resource.pingpong.Fill(initData(resource.Init, resource.format, width, height))
+	default:
+		tex, err := LoadTextureData(width, height, resource.format.Internal, initData(resource.Init, resource.format, width, height))
+		if err != nil {
+			panic(err)
+		}
+
+		resource.texture = tex
+	}
+}
+
+// initData starting texels of a resource, an image for RGBA8 and slices of the format's channels otherwise
+func initData(init string, format TextureFormat, width, height int) interface{} {
+	if format == FormatRGBA8 {
+		return initImage(init, width, height)
+	}
+
+	values := make([]float32, width*height*format.Channels)
+	for i := range values {
+		switch init {
+		case "random":
+			values[i] = rand.Float32()
+		case "white":
+			values[i] = 1
+		case "black":
+			if format.Channels == 4 && i%4 == 3 {
+				values[i] = 1
+			}
+		}
+	}
+
+	switch {
+	case format.Float:
+		return values
+	case format.Integer:
+		data := make([]uint32, len(values))
+		for i, v := range values {
+			if init == "random" {
+				data[i] = rand.Uint32()
+			} else {
+				data[i] = uint32(v)
+			}
+		}
+
+		return data
+	}
+
+	data := make([]uint8, len(values))
+	for i, v := range values {
+		data[i] = uint8(v * 255)
+	}
+
+	return data
+}
+
+func initImage(init string, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	switch init {
+	case "random":
+		rand.Read(img.Pix)
+	case "black", "white":
+		c := color.RGBA{0, 0, 0, 255}
+		if init == "white" {
+			c = color.RGBA{255, 255, 255, 255}
+		}
+
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+
+	return img
+}
+
+// Render run every pass once
+func (self *RenderGraph) Render(t float64) {
+	mx, my := float64(self.Width)/2, float64(self.Height)/2
+	if self.surface != nil {
+		mx, my = self.surface.GetCursorPos()
+	}
+
+	State.BindVertexArray(self.quad.VAO())
+	for _, pass := range self.order {
+		width, height := self.Width, self.Height
+		var output *GraphResource
+		if pass.Output == "" {
+			State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+		} else {
+			output = self.Resources[pass.Output]
+			width, height = output.size(self.Width, self.Height)
+			if output.pingpong != nil {
+				output.pingpong.Bind()
+			} else {
+				State.BindFramebuffer(gl.FRAMEBUFFER, self.fbo.Handle)
+				gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, output.texture.Handle, 0)
+			}
+		}
+
+		gl.Viewport(0, 0, int32(width), int32(height))
+
+		shader := pass.Shader.Use().
+			Uniform1f("u_time", float32(t)).
+			Uniform1i("u_frame", int32(self.Frame)).
+			Uniform2f("u_resolution", float32(width), float32(height)).
+			Uniform2f("u_mouse", float32(mx), float32(self.Height)-float32(my)).
+			Uniform2f("scale", float32(width), float32(height))
+
+		// bind inputs in name order so units are stable
+		names := make([]string, 0, len(pass.Inputs))
+		for name := range pass.Inputs {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for i, name := range names {
+			self.Resources[pass.Inputs[name]].Texture().Activate(gl.TEXTURE0 + uint32(i))
+			shader.Uniform1i(name, int32(i))
+		}
+
+		for name, value := range pass.Uniforms {
+			shader.UniformValue(name, value)
+		}
+
+		if self.Apply != nil {
+			shader = self.Apply(shader)
+		}
+
+		if pass.Apply != nil {
+			pass.Apply(shader)
+		}
+
+		self.quad.Draw()
+
+		if output != nil && output.pingpong != nil {
+			output.pingpong.Swap()
+		}
+	}
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	gl.Viewport(0, 0, int32(self.Width), int32(self.Height))
+	self.Frame++
+}
+
+// Resize every resource to follow the new graph size, contents are scaled
+func (self *RenderGraph) Resize(width, height int) {
+	self.Width, self.Height = width, height
+	for _, resource := range self.Resources {
+		self.allocate(resource)
+	}
+}
+
+func (self *RenderGraph) Cleanup() {
+	for _, resource := range self.Resources {
+		if resource.pingpong != nil {
+			resource.pingpong.Cleanup()
+			resource.pingpong = nil
+		}
+
+		if resource.texture != nil {
+			State.DeleteTextures(resource.texture.Handle)
+			resource.texture = nil
+		}
+	}
+
+	for _, pass := range self.Passes {
+		if pass.Shader.Program != nil {
+			pass.Shader.Cleanup()
+		}
+	}
+
+	if self.fbo != nil {
+		State.DeleteFramebuffers(self.fbo.Handle)
+		self.fbo = nil
+	}
+}
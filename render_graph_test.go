@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"encoding/json"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestGraphResourceFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   string
+		format TextureFormat
+		err    bool
+	}{
+		{"default", `{"pingpong": true}`, FormatRGBA8, false},
+		{"float", `{"format": "RGBA32F"}`, FormatRGBA32F, false},
+		{"any case", `{"format": "rgba16f"}`, FormatRGBA16F, false},
+		{"integer", `{"format": "R32UI"}`, FormatR32UI, false},
+		{"depth", `{"format": "DEPTH_COMPONENT24"}`, TextureFormat{}, true},
+		{"unknown", `{"format": "RGBA9"}`, TextureFormat{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resource GraphResource
+			if err := json.Unmarshal([]byte(tt.json), &resource); err != nil {
+				t.Fatal(err)
+			}
+
+			err := resource.parseFormat()
+			if tt.err {
+				if err == nil {
+					t.Errorf("format %v accepted", resource.Format)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resource.format != tt.format {
+				t.Errorf("format %v, want %v", resource.format, tt.format)
+			}
+		})
+	}
+}
+
+func TestInitData(t *testing.T) {
+	tests := []struct {
+		name   string
+		init   string
+		format TextureFormat
+		want   interface{}
+	}{
+		{"rgba8 white", "white", FormatRGBA8, []uint8{255, 255, 255, 255, 255, 255, 255, 255}},
+		{"rgba8 black", "black", FormatRGBA8, []uint8{0, 0, 0, 255, 0, 0, 0, 255}},
+		{"rgba32f black", "black", FormatRGBA32F, []float32{0, 0, 0, 1, 0, 0, 0, 1}},
+		{"r32f white", "white", FormatR32F, []float32{1, 1}},
+		{"rg8 white", "white", FormatRG8, []uint8{255, 255, 255, 255}},
+		{"r32ui clear", "clear", FormatR32UI, []uint32{0, 0}},
+		{"r32ui white", "white", FormatR32UI, []uint32{1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := initData(tt.init, tt.format, 2, 1)
+			if img, ok := got.(*image.RGBA); ok {
+				got = img.Pix
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%v, want %v", got, tt.want)
+			}
+
+			if _, _, _, count, err := tt.format.transfer(got); err != nil || count != 2 {
+				t.Errorf("uploads %v texels: %v", count, err)
+			}
+		})
+	}
+}
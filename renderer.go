@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/png"
+	"math"
 	"os"
 	"time"
 
@@ -12,11 +12,17 @@ import (
 	"github.com/gen2brain/beeep"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"gogl/headless"
 )
 
 var BuildDate = ""
 var HotProgram Program
 
+// FixedStepClock start windowed renderers on a fixed step clock, e.g. FIXED_STEP=1 to
+// step exactly 1/refresh rate per frame however long frames take
+var FixedStepClock = os.Getenv("FIXED_STEP") != ""
+
 func HotRender(kill <-chan bool, window *glfw.Window) {
 	fmt.Println(BuildDate)
 
@@ -27,20 +33,107 @@ func HotRender(kill <-chan bool, window *glfw.Window) {
 	NewRenderer(window, HotProgram).Run(kill)
 }
 
+// HeadlessRender run HotProgram offscreen for a number of frames without a window.
+// The last frame is captured, and every frame is recorded when record is set.
+// A poster size captures the last frame in tiles at that size instead.
+func HeadlessRender(width, height, frames int, record bool, posterWidth, posterHeight int) error {
+	if HotProgram == nil {
+		panic("hot program not set")
+	}
+
+	ctx, err := headless.NewContext()
+	if err != nil {
+		return err
+	}
+	defer ctx.Destroy()
+
+	r := NewHeadlessRenderer(HotProgram, width, height, frames, 60)
+	defer r.Headless.Cleanup()
+
+	if record {
+		r.Recorder.Start()
+	}
+
+	r.Run(nil)
+
+	if r.Recorder.On {
+		r.Recorder.End()
+		r.Recorder.Wait()
+	}
+
+	if posterWidth > 0 && posterHeight > 0 {
+		return r.CaptureTiled(posterWidth, posterHeight)
+	}
+
+	return r.Capture()
+}
+
+// OfflineRender run HotProgram on the current context for a number of frames at a fixed rate.
+// Every frame is passed to the encoders no matter how long it takes to render.
+func OfflineRender(width, height, frames int, fps float64, encoders ...FrameEncoder) error {
+	if HotProgram == nil {
+		panic("hot program not set")
+	}
+
+	r := NewHeadlessRenderer(HotProgram, width, height, frames, fps)
+	defer r.Headless.Cleanup()
+	defer r.Cleaner.Run()
+
+	gl.Viewport(0, 0, int32(width), int32(height))
+	duration := time.Duration(float64(time.Second) / fps)
+	progress := int(math.Max(1, fps))
+	for !r.Surface.ShouldClose() {
+		r.Clock.Tick()
+		r.Program.Render(r.Clock.Time())
+		r.Surface.SwapBuffers()
+
+		img := r.ReadFrame()
+		for _, enc := range encoders {
+			if err := enc.Encode(img, duration); err != nil {
+				return err
+			}
+		}
+
+		if r.Headless.Frame%progress == 0 {
+			fmt.Printf("rendered %v / %v frames\n", r.Headless.Frame, frames)
+		}
+	}
+
+	for _, enc := range encoders {
+		if err := enc.Close(); err != nil {
+			return err
+		}
+
+		fmt.Println("saved", enc.Name())
+	}
+
+	return nil
+}
+
 var CaptureCmd = "capture"
+var CaptureTiledCmd = "capture_tiled"
 
 // Renderer handles running our programs
 type Renderer struct {
 	Program Program
 	Window  *glfw.Window
+	Surface Surface
+
+	// set when rendering offscreen, Window is nil
+	Headless *HeadlessSurface
 
 	PauseBufferSwap   bool
 	Wireframe         bool
+	Clock             *Clock
 	Tick              *time.Ticker
 	RefreshRate       float64
 	UnlockedFrameRate bool
 	Width, Height     int
 
+	// tiled captures are PosterScale times the screen size, rendered in TileSize tiles
+	PosterScale int
+	TileSize    int
+
 	Cmds CmdChannels
 
 	*Recorder
@@ -54,6 +147,7 @@ func NewRenderer(window *glfw.Window, program Program) *Renderer {
 	r := &Renderer{
 		Program: nil,
 		Window:  window,
+		Surface: window,
 
 		KeyPressDetection: NewKeyPressDetection(),
 		Cmds:              NewCmdChannels(),
@@ -71,35 +165,114 @@ func NewRenderer(window *glfw.Window, program Program) *Renderer {
 		panic(err)
 	}
 
+	// bindings of any earlier context no longer apply
+	State.Reset()
+
 	// get current resolution
 	r.Width, r.Height = r.Window.GetFramebufferSize()
 
 	// get refresh rate
 	r.RefreshRate = float64(glfw.GetPrimaryMonitor().GetVideoMode().RefreshRate)
 	r.Tick = time.NewTicker(time.Duration(1000/r.RefreshRate) * time.Millisecond)
+	r.Clock = NewClock(glfw.GetTime, r.RefreshRate)
+	if FixedStepClock {
+		r.Clock.SetMode(ClockFixedStep)
+	}
+
+	r.Recorder.FPS = r.RefreshRate
+	r.Recorder.Clock = r.Clock
+
+	// register callbacks
+	r.Window.SetKeyCallback(r.KeyCallback)
+	r.Window.SetSizeCallback(r.ResizeCallback)
+	r.Window.SetDropCallback(r.DropCallback)
+
+	r.load(program)
+	return r
+}
+
+// NewHeadlessRenderer create a renderer drawing to an offscreen framebuffer on a fixed step clock.
+// Requires a current OpenGL context, see headless.NewContext.
+func NewHeadlessRenderer(program Program, width, height, frames int, fps float64) *Renderer {
+	// Initialize Glow
+	if err := gl.Init(); err != nil {
+		panic(err)
+	}
 
+	// bindings of any earlier context no longer apply
+	State.Reset()
+
+	hs := NewHeadlessSurface(width, height, frames)
+	r := &Renderer{
+		Program:  nil,
+		Surface:  hs,
+		Headless: hs,
+
+		Width:       width,
+		Height:      height,
+		RefreshRate: fps,
+
+		// render frames back to back
+		Clock:             NewFixedClock(fps),
+		Tick:              time.NewTicker(1),
+		UnlockedFrameRate: true,
+
+		KeyPressDetection: NewKeyPressDetection(),
+		KeyRegister:       NewKeyRegister(),
+		Cmds:              NewCmdChannels(),
+
+		Recorder: NewRecorder(hs),
+
+		Cleaner: &Cleaner{},
+	}
+
+	// nobody to notify
+	Notifications = false
+	r.Recorder.FPS = fps
+	r.Recorder.Clock = r.Clock
+
+	r.load(program)
+	return r
+}
+
+func (self *Renderer) load(program Program) {
 	// register key press channels
-	r.Cmds.Register(CaptureCmd)
+	self.Cmds.Register(CaptureCmd)
+	self.Cmds.Register(CaptureTiledCmd)
+	if self.PosterScale == 0 {
+		self.PosterScale = 4
+	}
+
+	if self.TileSize == 0 {
+		self.TileSize = 1024
+	}
 
 	// print some info
 	version := gl.GoStr(gl.GetString(gl.VERSION))
 	fmt.Println("OpenGL version", version)
-	fmt.Println("Refresh rate", r.RefreshRate)
-
-	// register callbacks
-	r.Window.SetKeyCallback(r.KeyCallback)
-	r.Window.SetSizeCallback(r.ResizeCallback)
+	fmt.Println("Refresh rate", self.RefreshRate)
 
 	// Configure global settings
 	gl.ColorMask(true, true, true, true)
 	gl.ClearColor(0.0, 0.0, 0.0, 0.0)
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 
-	r.Program = program
-	r.Program.LoadR(r)
-	return r
+	self.Program = program
+	self.Program.LoadR(self)
+
+	// only the first program loaded, hot reloads keep their state
+	if RestoreFile != "" {
+		if err := self.Restore(RestoreFile); err != nil {
+			fmt.Println(err)
+		}
+
+		RestoreFile = ""
+	}
 }
 
+// RestoreFile capture the next program loaded is restored from
+var RestoreFile = ""
+
 func (self *Renderer) SetTickRate(rr float64) {
 	if rr <= 0.0 {
 		self.Tick.Reset(1)
@@ -117,8 +290,8 @@ func (self *Renderer) Run(kill <-chan bool) {
 	defer self.Cleaner.Run()
 
 	frames := 0.0
-	previousTime := glfw.GetTime()
-	for !self.Window.ShouldClose() {
+	previousTime := self.wallTime()
+	for !self.Surface.ShouldClose() {
 		select {
 		// kill
 		case <-kill:
@@ -126,43 +299,80 @@ func (self *Renderer) Run(kill <-chan bool) {
 		// capture frame
 		case <-self.Cmds[CaptureCmd]:
 			self.Capture()
+		case <-self.Cmds[CaptureTiledCmd]:
+			w, h := self.Surface.GetFramebufferSize()
+			if err := self.CaptureTiled(w*self.PosterScale, h*self.PosterScale); err != nil {
+				fmt.Println(err)
+			}
 		// frame limiter
 		case <-self.Tick.C:
-			currentTime := glfw.GetTime()
+			currentTime := self.wallTime()
 			frames++
 			delta := currentTime - previousTime
 			if delta > 1.0 {
 				fps := frames / delta
-				self.Window.SetTitle(fmt.Sprintf("%.2f FPS @ %v x %v", fps, self.Width, self.Height))
+				title := fmt.Sprintf("%.2f FPS @ %v x %v", fps, self.Width, self.Height)
+				if self.Recorder.On {
+					title = fmt.Sprintf("%v | %v", title, self.Recorder.Stats())
+				}
+
+				self.Surface.SetTitle(title)
 
 				previousTime = currentTime
 				frames = 0
 			}
 
 			// run
-			self.Program.Render(currentTime)
+			advanced := self.Clock.Tick()
+			drawn := advanced
+			if advanced {
+				self.Program.Render(self.Clock.Time())
+			} else if p, ok := self.Program.(PausedProgram); ok {
+				drawn = p.RenderPaused(self.Clock.Time())
+			}
 
 			// maintenance
-			if !self.PauseBufferSwap {
-				self.Window.SwapBuffers()
+			if drawn && (!self.PauseBufferSwap || self.Headless != nil) {
+				self.Surface.SwapBuffers()
 			}
 
-			glfw.PollEvents()
+			if self.Headless == nil {
+				glfw.PollEvents()
+			}
 
 			// record
-			if self.Recorder.On {
+			if advanced && self.Recorder.On {
 				self.Recorder.Capture()
 			}
 		}
 	}
 }
 
+// wallTime seconds of real time, the clock stands in when headless
+func (self *Renderer) wallTime() float64 {
+	if self.Headless != nil {
+		return self.Clock.Time()
+	}
+
+	return glfw.GetTime()
+}
+
 func (self *Renderer) ResizeCallback(w *glfw.Window, width int, height int) {
 	self.Width, self.Height = w.GetFramebufferSize()
 	gl.Viewport(0, 0, int32(self.Width), int32(self.Height))
 
 	self.Program.ResizeCallback(w, self.Width, self.Height)
 }
+
+// DropCallback restore the program from a dropped capture
+func (self *Renderer) DropCallback(w *glfw.Window, names []string) {
+	for _, name := range names {
+		if err := self.Restore(name); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
 func (self *Renderer) CloseProgram() registrable.Registration {
 	return KeyCallbackRegistration{
 		action: glfw.Release,
@@ -198,6 +408,17 @@ func (self *Renderer) Screencapture() registrable.Registration {
 	}
 }
 
+func (self *Renderer) ScreencaptureTiled() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyF2,
+		mods:   glfw.ModShift,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Cmds.Issue(CaptureTiledCmd)
+		},
+	}
+}
+
 func (self *Renderer) Record() registrable.Registration {
 	return KeyCallbackRegistration{
 		action: glfw.Release,
@@ -212,6 +433,71 @@ func (self *Renderer) Record() registrable.Registration {
 	}
 }
 
+func (self *Renderer) TogglePause() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyF4,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Clock.TogglePause()
+		},
+	}
+}
+
+func (self *Renderer) StepFrame() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyF5,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Clock.Pause()
+			self.Clock.Step()
+		},
+	}
+}
+
+func (self *Renderer) ToggleClockMode() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyF7,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			if self.Clock.Mode == ClockFixedStep {
+				self.Clock.SetMode(ClockRealTime)
+			} else {
+				self.Clock.SetMode(ClockFixedStep)
+			}
+		},
+	}
+}
+
+func (self *Renderer) RewindClock() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyF8,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Clock.Rewind()
+		},
+	}
+}
+
+func (self *Renderer) SlowDownClock() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyLeftBracket,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Clock.SetScale(self.Clock.Scale / 2)
+		},
+	}
+}
+
+func (self *Renderer) SpeedUpClock() registrable.Registration {
+	return KeyCallbackRegistration{
+		action: glfw.Release,
+		key:    glfw.KeyRightBracket,
+		callback: func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			self.Clock.SetScale(self.Clock.Scale * 2)
+		},
+	}
+}
+
 func (self *Renderer) ToggleAlwaysOnTop() registrable.Registration {
 	return KeyCallbackRegistration{
 		action: glfw.Release,
@@ -268,6 +554,10 @@ func (self *Renderer) KeyCallback(
 }
 
 func (self *Renderer) Capture() error {
+	if framebufferIsFloat(ScreenFramebuffer) {
+		return self.CaptureHDR()
+	}
+
 	// create sub-folders
 	// folder := fmt.Sprintf("screencaptures/%v/", subFolder)
 	folder := "screencaptures/"
@@ -283,21 +573,11 @@ func (self *Renderer) Capture() error {
 	}
 
 	// create image
-	w, h := self.Window.GetFramebufferSize()
-	img := *image.NewRGBA(image.Rect(0, 0, w, h))
-
-	// set active frame buffer as main one
-	gl.ReadPixels(
-		0, 0,
-		int32(w), int32(h),
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(img.Pix),
-	)
+	img := self.ReadFrame()
 
 	// encode png
 	fmt.Println("Saving", name)
-	if err = png.Encode(f, &img); err != nil {
+	if err = EncodePNG(f, img, NewCaptureMetadata(self)); err != nil {
 		return err
 	}
 
@@ -306,7 +586,7 @@ func (self *Renderer) Capture() error {
 		return err
 	}
 
-	err = beeep.Notify("Screenshot Captured!", name, "applet.icns")
+	err = notify("Screenshot Captured!", name, "applet.icns")
 	if err != nil {
 		return err
 	}
@@ -314,6 +594,101 @@ func (self *Renderer) Capture() error {
 	return nil
 }
 
+// HDRCaptureFormat file type float framebuffers are captured as, exr or hdr
+var HDRCaptureFormat = "exr"
+
+// CaptureHDR save the screen framebuffer at full precision as HDRCaptureFormat
+func (self *Renderer) CaptureHDR() error {
+	folder := "screencaptures/"
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		os.MkdirAll(folder, 0700)
+	}
+
+	name := folder + time.Now().Format("20060102150405") + "." + HDRCaptureFormat
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	img := self.ReadFrameHDR()
+
+	fmt.Println("Saving", name)
+	if HDRCaptureFormat == "hdr" {
+		err = EncodeHDR(f, img)
+	} else {
+		err = EncodeEXR(f, img, &EXROptions{Compression: EXRZip, Float: true})
+	}
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return notify("Screenshot Captured!", name, "applet.icns")
+}
+
+// Notifications toggles desktop notifications, off when headless
+var Notifications = true
+
+func notify(title, message, appIcon string) error {
+	if !Notifications {
+		return nil
+	}
+
+	return beeep.Notify(title, message, appIcon)
+}
+
+// ReadFrame read the screen framebuffer into an image
+func (self *Renderer) ReadFrame() *image.RGBA {
+	w, h := self.Surface.GetFramebufferSize()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// set active frame buffer as main one
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, ScreenFramebuffer)
+	gl.ReadPixels(
+		0, 0,
+		int32(w), int32(h),
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(img.Pix),
+	)
+
+	return img
+}
+
+// ReadFrameHDR read the screen framebuffer into a float image
+func (self *Renderer) ReadFrameHDR() *HDRImage {
+	w, h := self.Surface.GetFramebufferSize()
+	img := NewHDRImage(image.Rect(0, 0, w, h))
+
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, ScreenFramebuffer)
+	gl.ReadPixels(
+		0, 0,
+		int32(w), int32(h),
+		gl.RGBA,
+		gl.FLOAT,
+		gl.Ptr(img.Pix),
+	)
+
+	return img
+}
+
+// framebufferIsFloat whether a framebuffer's first color attachment stores floats, the window's never does
+func framebufferIsFloat(handle uint32) bool {
+	if handle == 0 {
+		return false
+	}
+
+	var componentType int32
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, handle)
+	gl.GetFramebufferAttachmentParameteriv(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.FRAMEBUFFER_ATTACHMENT_COMPONENT_TYPE, &componentType)
+	return componentType == gl.FLOAT
+}
+
 type CmdChannels map[string](chan interface{})
 
 func NewCmdChannels() CmdChannels {
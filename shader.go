@@ -1,137 +1,17 @@
 package engine
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
-type HotShader struct {
-	VertFilename string
-	VertSource   string
-
-	FragFilename string
-	FragSource   string
-
-	BufferObject BufferObject
-	*Shader
-}
-
-type ShaderWatcher struct {
-	*fsnotify.Watcher
-
-	// map of watched files to their shader
-	WatchedFiles map[string]*HotShader
-}
-
-func NewShaderWatcher() *ShaderWatcher {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		panic(err)
-	}
-
-	return &ShaderWatcher{
-		Watcher:      watcher,
-		WatchedFiles: make(map[string]*HotShader),
-	}
-}
-
-func (self *ShaderWatcher) Add(shader *Shader, vertFilename, fragFilename string, bo BufferObject) {
-	log.Println("watching: ", vertFilename)
-	log.Println("watching: ", fragFilename)
-	self.Watcher.Add(vertFilename)
-	self.Watcher.Add(fragFilename)
-
-	// initial file load
-	// ignore read errors
-	vert, err := os.ReadFile(vertFilename)
-	frag, err := os.ReadFile(fragFilename)
-
-	vfb := filepath.Clean(vertFilename)
-	ffb := filepath.Clean(fragFilename)
-	hs := HotShader{
-		VertFilename: vfb,
-		VertSource:   string(vert),
-
-		FragFilename: ffb,
-		FragSource:   string(frag),
-
-		BufferObject: bo,
-		Shader:       shader,
-	}
-
-	if err == nil {
-		// attempt compile ignore any possible errors
-		hs.Shader.Compile(hs.VertSource, hs.FragSource, bo)
-	}
-
-	self.WatchedFiles[vfb] = &hs
-	self.WatchedFiles[ffb] = &hs
-}
-
-func (self *ShaderWatcher) Handle(event fsnotify.Event, ok bool) (*Shader, error) {
-	if !ok {
-		return nil, fmt.Errorf("ShaderWatcher.Handle: not okay")
-	}
-
-	// have to check "rename" for some reason?!
-	if event.Op == fsnotify.Create || event.Op == fsnotify.Write || event.Op == fsnotify.Rename {
-		log.Println("modified file:", event.Name)
-		self.Watcher.Add(event.Name)
-
-		hs, ok := self.WatchedFiles[event.Name]
-		if !ok {
-			return nil, fmt.Errorf("ShaderWatcher.Handle:  could not find watched file")
-		}
-
-		vert := hs.VertSource
-		frag := hs.FragSource
-
-		// read file in
-		data, err := os.ReadFile(event.Name)
-		if err != nil {
-			return hs.Shader, err
-		}
-
-		// if strings.ContainsAny
-		enb := filepath.Clean(event.Name)
-		vfb := filepath.Clean(hs.VertFilename)
-		ffb := filepath.Clean(hs.FragFilename)
-		switch enb {
-		case vfb:
-			vert = string(data)
-		case ffb:
-			frag = string(data)
-		}
-
-		// attempt to create new shader
-		err = hs.Compile(vert, frag, hs.BufferObject)
-		if err != nil {
-			return hs.Shader, err
-		}
-
-		if hs.VertSource != vert {
-			hs.VertSource = vert
-		}
-
-		if hs.FragSource != frag {
-			hs.FragSource = frag
-		}
-
-		return hs.Shader, nil
-	}
-
-	return nil, nil
-}
-
 type Shader struct {
 	Program *uint32
+
+	// active uniforms and attributes, uniform setters look up locations here
+	Reflection *ShaderReflection
 }
 
 func NewShader() *Shader {
@@ -155,14 +35,31 @@ func CompileShader(vertexShaderSource, fragmentShaderSource string, bo BufferObj
 	return shader, err
 }
 
+// Compile preprocess and link shader sources, includes are resolved against ShaderIncludeDirs
 func (self *Shader) Compile(vertexShaderSource, fragmentShaderSource string, bo BufferObject) error {
-	vertexShader, err := compileShader(vertexShaderSource+"\x00", gl.VERTEX_SHADER)
+	vert, err := PreprocessShader("", vertexShaderSource)
+	if err != nil {
+		return err
+	}
+
+	frag, err := PreprocessShader("", fragmentShaderSource)
+	if err != nil {
+		return err
+	}
+
+	return self.CompileSources(vert, frag, bo)
+}
+
+// CompileSources link already preprocessed shader sources
+func (self *Shader) CompileSources(vert, frag *ShaderSource, bo BufferObject) error {
+	vertexShader, err := compileShader(vert, "vertex", gl.VERTEX_SHADER)
 	if err != nil {
 		return err
 	}
 
-	fragmentShader, err := compileShader(fragmentShaderSource+"\x00", gl.FRAGMENT_SHADER)
+	fragmentShader, err := compileShader(frag, "fragment", gl.FRAGMENT_SHADER)
 	if err != nil {
+		gl.DeleteShader(vertexShader)
 		return err
 	}
 
@@ -180,7 +77,16 @@ func (self *Shader) Compile(vertexShaderSource, fragmentShaderSource string, bo
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
 
-		return fmt.Errorf("failed to link program: %v", log)
+		gl.DeleteProgram(program)
+		gl.DeleteShader(vertexShader)
+		gl.DeleteShader(fragmentShader)
+
+		// link logs can't tell which stage a source number belongs to
+		return &ShaderError{
+			Stage:       "link",
+			Diagnostics: ParseShaderLog("link", log, nil),
+			Log:         log,
+		}
 	}
 
 	gl.DetachShader(program, vertexShader)
@@ -190,21 +96,22 @@ func (self *Shader) Compile(vertexShaderSource, fragmentShaderSource string, bo
 	gl.DeleteShader(fragmentShader)
 
 	// bind buffer object
-	// gl.BindVertexArray(bo.VAO())
+	// State.BindVertexArray(bo.VAO())
 	// bind vertex coordinates
 	if self.Program != nil {
 		self.Cleanup()
 	}
 
 	self.Program = &program
+	self.Reflection = NewShaderReflection(program)
 
 	return nil
 }
 
-func compileShader(source string, shaderType uint32) (uint32, error) {
+func compileShader(source *ShaderSource, stage string, shaderType uint32) (uint32, error) {
 	shader := gl.CreateShader(shaderType)
 
-	csources, free := gl.Strs(source)
+	csources, free := gl.Strs(source.Source + "\x00")
 	gl.ShaderSource(shader, 1, csources, nil)
 	free()
 	gl.CompileShader(shader)
@@ -217,15 +124,20 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
 
-		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+		return 0, &ShaderError{
+			Stage:       stage,
+			Diagnostics: ParseShaderLog(stage, log, source.Files),
+			Log:         log,
+		}
 	}
 
 	return shader, nil
 }
 
 func (self Shader) Cleanup() {
-	gl.UseProgram(*self.Program)
+	State.UseProgram(*self.Program)
 	gl.DeleteProgram(*self.Program)
 }
 
@@ -234,188 +146,162 @@ func (self Shader) Apply(applyFN func(Shader) Shader) Shader {
 }
 
 func (self Shader) Use() Shader {
-	gl.UseProgram(*self.Program)
+	State.UseProgram(*self.Program)
 	return self
 }
 
 func (self Shader) Uniform1d(name string, value float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformDouble, 1)
 	gl.ProgramUniform1d(*self.Program, location, value)
 	return self
 }
 
 func (self Shader) Uniform1dv(name string, values []float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformDouble, 1)
 	gl.ProgramUniform1dv(*self.Program, location, int32(len(values)), &values[0])
 	return self
 }
 
 func (self Shader) Uniform1f(name string, value float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 1)
 	gl.ProgramUniform1f(*self.Program, location, value)
 	return self
 }
 
 func (self Shader) Uniform1fv(name string, values []float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 1)
 	gl.ProgramUniform1fv(*self.Program, location, int32(len(values)), &values[0])
 	return self
 }
 
 func (self Shader) Uniform1i(name string, value int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformInt, 1)
 	gl.ProgramUniform1i(*self.Program, location, value)
 	return self
 }
 
 func (self Shader) Uniform1iv(name string, values []int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformInt, 1)
 	gl.ProgramUniform1iv(*self.Program, location, int32(len(values)), &values[0])
 	return self
 }
 
 func (self Shader) Uniform2d(name string, v0, v1 float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformDouble, 2)
 	gl.ProgramUniform2d(*self.Program, location, v0, v1)
 	return self
 }
 
 func (self Shader) Uniform2dv(name string, values []float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform2dv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformDouble, 2)
+	gl.ProgramUniform2dv(*self.Program, location, int32(len(values)/2), &values[0])
 	return self
 }
 
 func (self Shader) Uniform2f(name string, v0, v1 float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 2)
 	gl.ProgramUniform2f(*self.Program, location, v0, v1)
 	return self
 }
 
 func (self Shader) Uniform2fv(name string, values []float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform2fv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformFloat, 2)
+	gl.ProgramUniform2fv(*self.Program, location, int32(len(values)/2), &values[0])
 	return self
 }
 
 func (self Shader) Uniform2i(name string, v0, v1 int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformInt, 2)
 	gl.ProgramUniform2i(*self.Program, location, v0, v1)
 	return self
 }
 
 func (self Shader) Uniform2iv(name string, values []int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform2iv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformInt, 2)
+	gl.ProgramUniform2iv(*self.Program, location, int32(len(values)/2), &values[0])
 	return self
 }
 
 func (self Shader) Uniform3d(name string, v0, v1, v2 float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformDouble, 3)
 	gl.ProgramUniform3d(*self.Program, location, v0, v1, v2)
 	return self
 }
 
 func (self Shader) Uniform3dv(name string, values []float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform3dv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformDouble, 3)
+	gl.ProgramUniform3dv(*self.Program, location, int32(len(values)/3), &values[0])
 	return self
 }
 
 func (self Shader) Uniform3f(name string, v0, v1, v2 float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 3)
 	gl.ProgramUniform3f(*self.Program, location, v0, v1, v2)
 	return self
 }
 
 func (self Shader) Uniform3fv(name string, values []float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform3fv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformFloat, 3)
+	gl.ProgramUniform3fv(*self.Program, location, int32(len(values)/3), &values[0])
 	return self
 }
 
 func (self Shader) Uniform3i(name string, v0, v1, v2 int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformInt, 3)
 	gl.ProgramUniform3i(*self.Program, location, v0, v1, v2)
 	return self
 }
 
 func (self Shader) Uniform3iv(name string, values []int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform3iv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformInt, 3)
+	gl.ProgramUniform3iv(*self.Program, location, int32(len(values)/3), &values[0])
 	return self
 }
 
 func (self Shader) Uniform4d(name string, v0, v1, v2, v3 float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformDouble, 4)
 	gl.ProgramUniform4d(*self.Program, location, v0, v1, v2, v3)
 	return self
 }
 
 func (self Shader) Uniform4dv(name string, values []float64) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform4dv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformDouble, 4)
+	gl.ProgramUniform4dv(*self.Program, location, int32(len(values)/4), &values[0])
 	return self
 }
 
 func (self Shader) Uniform4f(name string, v0, v1, v2, v3 float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 4)
 	gl.ProgramUniform4f(*self.Program, location, v0, v1, v2, v3)
 	return self
 }
 
 func (self Shader) Uniform4fv(name string, values []float32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform4fv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformFloat, 4)
+	gl.ProgramUniform4fv(*self.Program, location, int32(len(values)/4), &values[0])
 	return self
 }
 
 func (self Shader) Uniform4i(name string, v0, v1, v2, v3 int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformInt, 4)
 	gl.ProgramUniform4i(*self.Program, location, v0, v1, v2, v3)
 	return self
 }
 
 func (self Shader) Uniform4iv(name string, values []int32) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
-	gl.ProgramUniform4iv(*self.Program, location, int32(len(values)), &values[0])
+	location := self.uniformLocation(name, uniformInt, 4)
+	gl.ProgramUniform4iv(*self.Program, location, int32(len(values)/4), &values[0])
 	return self
 }
 
 func (self Shader) UniformMatrix4fv(name string, values *mgl32.Mat4) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloatMatrix, 16)
 	gl.ProgramUniformMatrix4fv(*self.Program, location, 1, false, &values[0])
 	return self
 }
 
 func (self Shader) UniformVec3(name string, values *mgl32.Vec3) Shader {
-	attr := fmt.Sprintf("%v\x00", name)
-	location := gl.GetUniformLocation(*self.Program, gl.Str(attr))
+	location := self.uniformLocation(name, uniformFloat, 3)
 	gl.ProgramUniform3f(*self.Program, location, values[0], values[1], values[2])
 	return self
 }
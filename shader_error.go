@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ShaderDiagnostic a single error or warning from a shader info log
+type ShaderDiagnostic struct {
+	// Stage vertex, fragment or link
+	Stage string
+	File  string
+	// Line and Column are 1 based, 0 when the driver didn't say
+	Line, Column int
+	// Severity error or warning
+	Severity string
+	Message  string
+}
+
+// String diagnostic as file:line:column: severity: message, the format editors expect
+func (self ShaderDiagnostic) String() string {
+	location := self.File
+	if location == "" {
+		location = self.Stage
+	}
+
+	if self.Line > 0 {
+		location += ":" + strconv.Itoa(self.Line)
+		if self.Column > 0 {
+			location += ":" + strconv.Itoa(self.Column)
+		}
+	}
+
+	return fmt.Sprintf("%v: %v: %v", location, self.Severity, self.Message)
+}
+
+// ShaderError a shader that failed to compile or link
+type ShaderError struct {
+	Stage       string
+	Diagnostics []ShaderDiagnostic
+	// Log info log as reported by the driver
+	Log string
+}
+
+func (self *ShaderError) Error() string {
+	lines := make([]string, 0, len(self.Diagnostics))
+	for _, d := range self.Diagnostics {
+		if d.Severity == "error" {
+			lines = append(lines, d.String())
+		}
+	}
+
+	if len(lines) == 0 {
+		return fmt.Sprintf("failed to %v shader: %v", self.verb(), strings.TrimSpace(self.Log))
+	}
+
+	return fmt.Sprintf("failed to %v shader:\n%v", self.verb(), strings.Join(lines, "\n"))
+}
+
+func (self *ShaderError) verb() string {
+	if self.Stage == "link" {
+		return "link"
+	}
+
+	return "compile " + self.Stage
+}
+
+// ShaderErrorDiagnostics diagnostics of a ShaderError, other errors become a single diagnostic
+func ShaderErrorDiagnostics(err error) []ShaderDiagnostic {
+	var shaderErr *ShaderError
+	if errors.As(err, &shaderErr) {
+		return shaderErr.Diagnostics
+	} else if err != nil {
+		return []ShaderDiagnostic{{Severity: "error", Message: err.Error()}}
+	}
+
+	return nil
+}
+
+var shaderLogFormats = []*regexp.Regexp{
+	// mesa: 0:12(5): error: message
+	regexp.MustCompile(`^(?P<source>\d+):(?P<line>\d+)\((?P<column>\d+)\):\s*(?P<severity>[a-zA-Z ]+?):\s*(?P<message>.*)$`),
+	// nvidia: 0(12) : error C0000: message
+	regexp.MustCompile(`^(?P<source>\d+)\((?P<line>\d+)\)\s*:\s*(?P<severity>[a-zA-Z]+)(?:\s+[A-Z]\d+)?\s*:\s*(?P<message>.*)$`),
+	// amd, apple and intel: ERROR: 0:12: message
+	regexp.MustCompile(`^(?P<severity>[A-Za-z]+):\s*(?P<source>\d+):(?P<line>\d+):\s*(?P<message>.*)$`),
+}
+
+// ParseShaderLog parse the diagnostics in a driver info log.
+// files maps the source string numbers in the log to file names.
+func ParseShaderLog(stage, log string, files []string) []ShaderDiagnostic {
+	diagnostics := make([]ShaderDiagnostic, 0)
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "\x00"))
+		if line == "" {
+			continue
+		}
+
+		d, ok := parseShaderLogLine(line, files)
+		if !ok {
+			// summaries such as "ERROR: 2 compilation errors.  No code generated."
+			if strings.Contains(strings.ToLower(line), "compilation error") {
+				continue
+			}
+
+			d = ShaderDiagnostic{Severity: "error", Message: line}
+			if len(files) > 0 {
+				d.File = files[0]
+			}
+		}
+
+		d.Stage = stage
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
+
+func parseShaderLogLine(line string, files []string) (ShaderDiagnostic, bool) {
+	for _, format := range shaderLogFormats {
+		match := format.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		d := ShaderDiagnostic{}
+		for i, name := range format.SubexpNames() {
+			switch name {
+			case "source":
+				source, _ := strconv.Atoi(match[i])
+				if source < len(files) {
+					d.File = files[source]
+				}
+			case "line":
+				d.Line, _ = strconv.Atoi(match[i])
+			case "column":
+				d.Column, _ = strconv.Atoi(match[i])
+			case "severity":
+				d.Severity = normalizeSeverity(match[i])
+			case "message":
+				d.Message = strings.TrimSpace(match[i])
+			}
+		}
+
+		return d, true
+	}
+
+	return ShaderDiagnostic{}, false
+}
+
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if strings.Contains(severity, "warn") {
+		return "warning"
+	}
+
+	return "error"
+}
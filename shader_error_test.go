@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestParseShaderLog(t *testing.T) {
+	files := []string{"main.glsl", "assets:gradients/lib/viridis.glsl"}
+
+	tests := []struct {
+		name string
+		log  string
+		want []ShaderDiagnostic
+	}{
+		{
+			name: "mesa",
+			log:  "0:12(5): error: `foo' undeclared\n1:3(10): warning: unused variable",
+			want: []ShaderDiagnostic{
+				{File: "main.glsl", Line: 12, Column: 5, Severity: "error", Message: "`foo' undeclared"},
+				{File: "assets:gradients/lib/viridis.glsl", Line: 3, Column: 10, Severity: "warning", Message: "unused variable"},
+			},
+		},
+		{
+			name: "mesa preprocessor error",
+			log:  "1:7(1): preprocessor error: syntax error",
+			want: []ShaderDiagnostic{
+				{File: "assets:gradients/lib/viridis.glsl", Line: 7, Column: 1, Severity: "error", Message: "syntax error"},
+			},
+		},
+		{
+			name: "nvidia",
+			log:  "0(12) : error C1008: undefined variable \"foo\"\n1(4) : warning C7050: \"c\" might be used before being initialized",
+			want: []ShaderDiagnostic{
+				{File: "main.glsl", Line: 12, Severity: "error", Message: "undefined variable \"foo\""},
+				{File: "assets:gradients/lib/viridis.glsl", Line: 4, Severity: "warning", Message: "\"c\" might be used before being initialized"},
+			},
+		},
+		{
+			name: "amd and intel",
+			log:  "ERROR: 1:9: 'vec5' : undeclared identifier\nWARNING: 0:2: extension not supported\nERROR: 2 compilation errors.  No code generated.",
+			want: []ShaderDiagnostic{
+				{File: "assets:gradients/lib/viridis.glsl", Line: 9, Severity: "error", Message: "'vec5' : undeclared identifier"},
+				{File: "main.glsl", Line: 2, Severity: "warning", Message: "extension not supported"},
+			},
+		},
+		{
+			name: "source string out of range",
+			log:  "5:1(1): error: bad",
+			want: []ShaderDiagnostic{
+				{Line: 1, Column: 1, Severity: "error", Message: "bad"},
+			},
+		},
+		{
+			name: "unrecognized lines are errors in the root file",
+			log:  "error: linking failed\x00\n\n",
+			want: []ShaderDiagnostic{
+				{File: "main.glsl", Severity: "error", Message: "error: linking failed"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseShaderLog("fragment", tt.log, files)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%v diagnostics %v, want %v", len(got), got, len(tt.want))
+			}
+
+			for i := range got {
+				want := tt.want[i]
+				want.Stage = "fragment"
+				if got[i] != want {
+					t.Errorf("diagnostic %v:\n got %+v\nwant %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseShaderLogIncludes driver line numbers of preprocessed source point back at the
+// file and line each driver's log refers to
+func TestParseShaderLogIncludes(t *testing.T) {
+	withShaderFiles(t, map[string]string{
+		"lib.glsl": "float a() {\n  return b;\n}",
+	})
+
+	src, err := PreprocessShader("main.glsl", "#version 410\n#include \"lib.glsl\"\nvoid main() {\n  c = a();\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the locations a compiler following the #line directives gives each line
+	locations := shaderLineLocations(t, src)
+	if locations["  return b;"] != "test:lib.glsl:2" || locations["  c = a();"] != "main.glsl:4" {
+		t.Fatalf("unexpected line locations %v", locations)
+	}
+
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{"mesa", "1:2(10): error: `b' undeclared\n0:4(3): error: `c' undeclared"},
+		{"nvidia", "1(2) : error C1008: undefined variable \"b\"\n0(4) : error C1008: undefined variable \"c\""},
+		{"amd", "ERROR: 1:2: 'b' : undeclared identifier\nERROR: 0:4: 'c' : undeclared identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseShaderLog("fragment", tt.log, src.Files)
+			want := [][2]interface{}{{"test:lib.glsl", 2}, {"main.glsl", 4}}
+			if len(got) != len(want) {
+				t.Fatalf("%v diagnostics, want %v", len(got), len(want))
+			}
+
+			for i, d := range got {
+				if d.File != want[i][0] || d.Line != want[i][1] {
+					t.Errorf("diagnostic %v at %v:%v, want %v:%v", i, d.File, d.Line, want[i][0], want[i][1])
+				}
+			}
+		})
+	}
+
+	err = &ShaderError{Stage: "fragment", Diagnostics: ParseShaderLog("fragment", tests[0].log, src.Files)}
+	want := "failed to compile fragment shader:\ntest:lib.glsl:2:10: error: `b' undeclared\nmain.glsl:4:3: error: `c' undeclared"
+	if err.Error() != want {
+		t.Errorf("error %q, want %q", err.Error(), want)
+	}
+}
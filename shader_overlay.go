@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"gogl/assets"
+)
+
+var (
+	overlayBackground = color.RGBA{0, 0, 0, 200}
+	overlayHighlight  = color.RGBA{140, 20, 20, 220}
+	overlayFile       = color.RGBA{255, 255, 255, 255}
+	overlayError      = color.RGBA{255, 90, 90, 255}
+	overlayWarning    = color.RGBA{255, 210, 80, 255}
+	overlaySource     = color.RGBA{200, 200, 200, 255}
+	overlayGutter     = color.RGBA{120, 120, 120, 255}
+)
+
+const overlayLineHeight = 14
+
+// ShaderErrorOverlay draws shader diagnostics over the screen, like shadertoy's error panel.
+// Set ShaderWatcher.OnDiagnostics to Update and call Draw after rendering a frame,
+// the overlay is hidden while there are no diagnostics.
+type ShaderErrorOverlay struct {
+	Diagnostics []ShaderDiagnostic
+	// Context source lines shown around each error line
+	Context int
+
+	quad    *VBuffer
+	shader  Shader
+	texture *Texture
+	dirty   bool
+}
+
+func NewShaderErrorOverlay() *ShaderErrorOverlay {
+	quad := NewV4Buffer(QuadVertices, 2, 4)
+	return &ShaderErrorOverlay{
+		Context: 1,
+
+		quad:   quad,
+		shader: MustCompileShader(assets.VertexShader, assets.OverlayShader, quad),
+	}
+}
+
+// Update replace the diagnostics shown, none hides the overlay
+func (self *ShaderErrorOverlay) Update(diagnostics []ShaderDiagnostic) {
+	self.Diagnostics = diagnostics
+	self.dirty = true
+}
+
+func (self *ShaderErrorOverlay) Active() bool {
+	return len(self.Diagnostics) > 0
+}
+
+// Draw blend the diagnostics over ScreenFramebuffer
+func (self *ShaderErrorOverlay) Draw(width, height int) {
+	if !self.Active() || width <= 0 || height <= 0 {
+		return
+	}
+
+	if self.texture == nil || self.texture.Image.Rect.Dx() != width || self.texture.Image.Rect.Dy() != height {
+		self.dirty = true
+	}
+
+	if self.dirty {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		DrawShaderDiagnostics(img, self.Diagnostics, self.Context)
+		self.upload(img)
+		self.dirty = false
+	}
+
+	depthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	blend := gl.IsEnabled(gl.BLEND)
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, ScreenFramebuffer)
+	gl.Viewport(0, 0, int32(width), int32(height))
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	// image.RGBA is alpha premultiplied
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+
+	self.texture.Activate(gl.TEXTURE0)
+	self.shader.Use().
+		Uniform1i("overlay", 0).
+		Uniform2f("scale", float32(width), float32(height))
+	self.quad.Draw()
+
+	if depthTest {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+
+	if !blend {
+		gl.Disable(gl.BLEND)
+	}
+}
+
+func (self *ShaderErrorOverlay) upload(img *image.RGBA) {
+	if self.texture == nil {
+		self.texture = LoadTexture(img)
+		return
+	}
+
+	self.texture.Image = img
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.texture.Handle)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(img.Rect.Size().X),
+		int32(img.Rect.Size().Y),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(img.Pix))
+}
+
+func (self *ShaderErrorOverlay) Cleanup() {
+	if self.texture != nil {
+		State.DeleteTextures(self.texture.Handle)
+		self.texture = nil
+	}
+
+	self.shader.Cleanup()
+}
+
+// DrawShaderDiagnostics draw a panel listing diagnostics at the top of img.
+// Each file is named once, followed by its messages and the offending
+// source lines with context lines around them, error lines highlighted.
+func DrawShaderDiagnostics(img *image.RGBA, diagnostics []ShaderDiagnostic, context int) {
+	p := &diagnosticPanel{img: img, y: 4, sources: make(map[string][]string)}
+
+	file := "\x00"
+	for _, d := range diagnostics {
+		if d.File != file {
+			file = d.File
+			p.space()
+			p.line(overlayFile, nil, shaderDiagnosticFileName(d))
+		}
+
+		col := overlayError
+		if d.Severity == "warning" {
+			col = overlayWarning
+		}
+
+		location := ""
+		if d.Line > 0 {
+			location = fmt.Sprintf("%v: ", d.Line)
+		}
+
+		p.line(col, nil, fmt.Sprintf("  %v%v: %v", location, d.Severity, d.Message))
+
+		source := p.source(d.File)
+		if d.Line <= 0 || d.Line > len(source) {
+			continue
+		}
+
+		for n := d.Line - context; n <= d.Line+context; n++ {
+			if n < 1 || n > len(source) {
+				continue
+			}
+
+			var highlight color.Color
+			if n == d.Line {
+				highlight = overlayHighlight
+			}
+
+			code := strings.ReplaceAll(source[n-1], "\t", "    ")
+			p.gutter(n, highlight, code)
+		}
+	}
+
+	// shade the panel behind the text, done last so it fits the text
+	panel := image.Rect(0, 0, img.Rect.Dx(), p.y+4).Intersect(img.Rect)
+	for y := panel.Min.Y; y < panel.Max.Y; y++ {
+		for x := panel.Min.X; x < panel.Max.X; x++ {
+			if img.RGBAAt(x, y).A == 0 {
+				img.SetRGBA(x, y, overlayBackground)
+			}
+		}
+	}
+}
+
+type diagnosticPanel struct {
+	img *image.RGBA
+	y   int
+
+	// source lines of each file, read once
+	sources map[string][]string
+}
+
+// space a blank half line between files, except at the top
+func (self *diagnosticPanel) space() {
+	if self.y > 4 {
+		self.y += overlayLineHeight / 2
+	}
+}
+
+func (self *diagnosticPanel) line(col, highlight color.Color, text string) {
+	self.text(4, col, highlight, text)
+	self.y += overlayLineHeight
+}
+
+// gutter a source line after its line number
+func (self *diagnosticPanel) gutter(n int, highlight color.Color, code string) {
+	number := fmt.Sprintf("%6d |", n)
+	self.text(4, overlayGutter, highlight, number)
+	self.text(4+(len(number)+1)*basicfont.Face7x13.Advance, overlaySource, nil, code)
+	self.y += overlayLineHeight
+}
+
+func (self *diagnosticPanel) text(x int, col, highlight color.Color, text string) {
+	if highlight != nil {
+		bar := image.Rect(0, self.y, self.img.Rect.Dx(), self.y+overlayLineHeight)
+		draw.Draw(self.img, bar, image.NewUniform(highlight), image.Point{}, draw.Src)
+	}
+
+	d := &font.Drawer{
+		Dst:  self.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, self.y+basicfont.Face7x13.Ascent),
+	}
+
+	d.DrawString(text)
+}
+
+func (self *diagnosticPanel) source(file string) []string {
+	if lines, ok := self.sources[file]; ok {
+		return lines
+	}
+
+	data, err := readDiagnosticSource(file)
+	if err != nil {
+		self.sources[file] = nil
+		return nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	self.sources[file] = lines
+	return lines
+}
+
+func shaderDiagnosticFileName(d ShaderDiagnostic) string {
+	if d.File != "" {
+		return d.File
+	}
+
+	if d.Stage != "" {
+		return d.Stage + " shader"
+	}
+
+	return "shader"
+}
+
+// readDiagnosticSource read a file named by a diagnostic, embedded files are prefixed with their include dir
+func readDiagnosticSource(file string) ([]byte, error) {
+	if file == "" || file == "<source>" {
+		return nil, fs.ErrNotExist
+	}
+
+	for _, dir := range ShaderIncludeDirs {
+		if dir.Name == "" || !strings.HasPrefix(file, dir.Name+":") {
+			continue
+		}
+
+		return fs.ReadFile(dir.FS, strings.TrimPrefix(file, dir.Name+":"))
+	}
+
+	return os.ReadFile(file)
+}
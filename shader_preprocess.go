@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gogl/assets"
+)
+
+// ShaderIncludeDir a filesystem #include paths are resolved against
+type ShaderIncludeDir struct {
+	// Name shown in errors for files found here, empty for the working directory
+	Name string
+	// Path directory on disk FS reads, empty when embedded
+	Path string
+	fs.FS
+}
+
+// ShaderIncludeDirs searched in order for #include paths not found next to the including file
+var ShaderIncludeDirs = []ShaderIncludeDir{
+	{Name: "", Path: ".", FS: os.DirFS(".")},
+	{Name: "assets", FS: assets.ShaderFS},
+}
+
+// ShaderSource preprocessed shader source
+type ShaderSource struct {
+	Source string
+	// Files names of the source string numbers used in #line directives, 0 is the root
+	Files []string
+	// Dependencies files read from disk, the root included when it has a name
+	Dependencies []string
+}
+
+// shaderFile where a shader source was loaded from
+type shaderFile struct {
+	dir  *ShaderIncludeDir
+	path string
+}
+
+func (self shaderFile) String() string {
+	if self.path == "" {
+		return "<source>"
+	}
+
+	if self.dir == nil || self.dir.Name == "" {
+		return self.path
+	}
+
+	return self.dir.Name + ":" + self.path
+}
+
+// disk path of the file on disk, empty when embedded or unnamed
+func (self shaderFile) disk() string {
+	switch {
+	case self.path == "":
+		return ""
+	case self.dir == nil:
+		return filepath.FromSlash(self.path)
+	case self.dir.Path != "":
+		return filepath.Join(self.dir.Path, filepath.FromSlash(self.path))
+	}
+
+	return ""
+}
+
+type shaderPreprocessor struct {
+	out          strings.Builder
+	files        []string
+	dependencies []string
+
+	// files included with #pragma once
+	once map[string]bool
+	// macros that have been #defined, used to skip guarded files
+	defined map[string]bool
+	// files being included, for cycle detection
+	stack []string
+}
+
+// PreprocessShader resolve #include and #import directives in source.
+// name is the file source was read from, relative includes are resolved next to it.
+// An empty name resolves every include against ShaderIncludeDirs.
+func PreprocessShader(name, source string) (*ShaderSource, error) {
+	return preprocessShader(shaderFile{path: filepath.ToSlash(name)}, source)
+}
+
+// LoadShaderSource read and preprocess a shader from the first of ShaderIncludeDirs containing it
+func LoadShaderSource(name string) (*ShaderSource, error) {
+	file, data, err := readShaderFile(shaderFile{}, filepath.ToSlash(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return preprocessShader(file, string(data))
+}
+
+func preprocessShader(root shaderFile, source string) (*ShaderSource, error) {
+	p := &shaderPreprocessor{
+		once:    make(map[string]bool),
+		defined: make(map[string]bool),
+	}
+
+	if err := p.process(root, source); err != nil {
+		return nil, err
+	}
+
+	return &ShaderSource{Source: p.out.String(), Files: p.files, Dependencies: p.dependencies}, nil
+}
+
+func (self *shaderPreprocessor) process(file shaderFile, source string) error {
+	id := file.String()
+	for i, f := range self.stack {
+		if f == id {
+			return fmt.Errorf("shader include cycle: %v -> %v", strings.Join(self.stack[i:], " -> "), id)
+		}
+	}
+
+	self.stack = append(self.stack, id)
+	defer func() { self.stack = self.stack[:len(self.stack)-1] }()
+
+	number := len(self.files)
+	self.files = append(self.files, id)
+	if disk := file.disk(); disk != "" {
+		self.dependencies = append(self.dependencies, disk)
+	}
+
+	// offset from line index to line number, moved by #line directives in the source
+	offset := 1
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		directive, arg := parseDirective(line)
+		switch directive {
+		case "include", "import":
+			include, err := parseIncludePath(arg)
+			if err != nil {
+				return fmt.Errorf("%v:%v: %v", id, i+offset, err)
+			}
+
+			child, data, err := readShaderFile(file, include)
+			if err != nil {
+				return fmt.Errorf("%v:%v: %v", id, i+offset, err)
+			}
+
+			if self.skip(child, string(data)) {
+				self.out.WriteString("\n")
+				continue
+			}
+
+			fmt.Fprintf(&self.out, "#line 1 %v\n", len(self.files))
+			if err = self.process(child, string(data)); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(&self.out, "\n#line %v %v\n", i+1+offset, number)
+			continue
+		case "line":
+			// the next line is numbered as given
+			if fields := strings.Fields(arg); len(fields) > 0 {
+				if n, err := strconv.Atoi(fields[0]); err == nil {
+					offset = n - (i + 1)
+				}
+			}
+		case "pragma":
+			if strings.TrimSpace(arg) == "once" {
+				self.once[id] = true
+				self.out.WriteString("\n")
+				continue
+			}
+		case "version":
+			// only the root may declare a version
+			if number != 0 {
+				self.out.WriteString("// " + line + "\n")
+				continue
+			}
+		case "define":
+			if fields := strings.Fields(arg); len(fields) > 0 {
+				self.defined[macroName(fields[0])] = true
+			}
+		}
+
+		self.out.WriteString(line)
+		if i < len(lines)-1 {
+			self.out.WriteString("\n")
+		}
+	}
+
+	return nil
+}
+
+// skip whether a file was already included with #pragma once or an include guard
+func (self *shaderPreprocessor) skip(file shaderFile, source string) bool {
+	if self.once[file.String()] {
+		return true
+	}
+
+	guard := includeGuard(source)
+	return guard != "" && self.defined[guard]
+}
+
+// includeGuard macro of a classic #ifndef X / #define X guard, empty when unguarded
+func includeGuard(source string) string {
+	directives := make([][2]string, 0, 2)
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+
+		directive, arg := parseDirective(line)
+		if directive == "" {
+			break
+		}
+
+		directives = append(directives, [2]string{directive, strings.TrimSpace(arg)})
+		if len(directives) == 2 {
+			break
+		}
+	}
+
+	if len(directives) < 2 || directives[0][0] != "ifndef" || directives[1][0] != "define" {
+		return ""
+	}
+
+	fields := strings.Fields(directives[1][1])
+	if len(fields) == 0 || fields[0] != directives[0][1] {
+		return ""
+	}
+
+	return fields[0]
+}
+
+// parseDirective split a preprocessor line into its directive and argument
+func parseDirective(line string) (string, string) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "#") {
+		return "", ""
+	}
+
+	trimmed = strings.TrimSpace(trimmed[1:])
+	end := strings.IndexAny(trimmed, " \t\"<")
+	if end == -1 {
+		return trimmed, ""
+	}
+
+	return trimmed[:end], trimmed[end:]
+}
+
+func parseIncludePath(arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if len(arg) >= 2 && (arg[0] == '"' && arg[len(arg)-1] == '"' || arg[0] == '<' && arg[len(arg)-1] == '>') {
+		return arg[1 : len(arg)-1], nil
+	}
+
+	return "", fmt.Errorf("malformed include %v", arg)
+}
+
+func macroName(name string) string {
+	if i := strings.Index(name, "("); i != -1 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// readShaderFile find an include next to the including file, then in ShaderIncludeDirs
+func readShaderFile(from shaderFile, name string) (shaderFile, []byte, error) {
+	// absolute paths are read from disk as is
+	if filepath.IsAbs(name) {
+		data, err := os.ReadFile(name)
+		return shaderFile{path: name}, data, err
+	}
+
+	candidates := make([]shaderFile, 0, len(ShaderIncludeDirs)+1)
+	if from.path != "" {
+		relative := path.Join(path.Dir(from.path), name)
+		candidates = append(candidates, shaderFile{dir: from.dir, path: relative})
+	}
+
+	for i := range ShaderIncludeDirs {
+		candidates = append(candidates, shaderFile{dir: &ShaderIncludeDirs[i], path: path.Clean(name)})
+	}
+
+	for _, c := range candidates {
+		var data []byte
+		var err error
+		if c.dir == nil {
+			data, err = os.ReadFile(filepath.FromSlash(c.path))
+		} else if fs.ValidPath(c.path) {
+			data, err = fs.ReadFile(c.dir.FS, c.path)
+		} else {
+			continue
+		}
+
+		if err == nil {
+			return c, data, nil
+		}
+	}
+
+	return shaderFile{}, nil, fmt.Errorf("shader include %q not found", name)
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// withShaderFiles resolve includes against files only for the rest of the test
+func withShaderFiles(t *testing.T, files map[string]string) {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+
+	saved := ShaderIncludeDirs
+	ShaderIncludeDirs = []ShaderIncludeDir{{Name: "test", FS: fsys}}
+	t.Cleanup(func() { ShaderIncludeDirs = saved })
+}
+
+func TestPreprocessShader(t *testing.T) {
+	files := map[string]string{
+		"lib/a.glsl":       "float a() { return 1.0; }",
+		"lib/once.glsl":    "#pragma once\nfloat once() { return 2.0; }",
+		"lib/guarded.glsl": "// guarded\n#ifndef GUARDED\n#define GUARDED\nfloat guarded() { return 3.0; }\n#endif",
+		"lib/nested.glsl":  "#include \"a.glsl\"\nfloat nested() { return a(); }",
+		"lib/version.glsl": "#version 410\nfloat v() { return 4.0; }",
+		"lib/cycle_a.glsl": "#include \"cycle_b.glsl\"",
+		"lib/cycle_b.glsl": "#include \"cycle_a.glsl\"",
+		"lib/self.glsl":    "#include \"self.glsl\"",
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		// counts of substrings in the output
+		want map[string]int
+		// files in source string order
+		files []string
+		err   string
+	}{
+		{
+			name:   "include",
+			source: "#version 410\n#include \"lib/a.glsl\"\nvoid main() {}",
+			want:   map[string]int{"float a()": 1, "#line 1 1\n": 1, "\n#line 3 0\n": 1},
+			files:  []string{"<source>", "test:lib/a.glsl"},
+		},
+		{
+			name:   "import",
+			source: "#import <lib/a.glsl>",
+			want:   map[string]int{"float a()": 1},
+			files:  []string{"<source>", "test:lib/a.glsl"},
+		},
+		{
+			name:   "relative to the including file",
+			source: "#include \"lib/nested.glsl\"",
+			want:   map[string]int{"float a()": 1, "float nested()": 1, "#line 1 2\n": 1, "\n#line 2 1\n": 1},
+			files:  []string{"<source>", "test:lib/nested.glsl", "test:lib/a.glsl"},
+		},
+		{
+			name:   "pragma once",
+			source: "#include \"lib/once.glsl\"\n#include \"lib/once.glsl\"",
+			want:   map[string]int{"float once()": 1, "#pragma": 0},
+			files:  []string{"<source>", "test:lib/once.glsl"},
+		},
+		{
+			name:   "include guard",
+			source: "#include \"lib/guarded.glsl\"\n#include \"lib/guarded.glsl\"",
+			want:   map[string]int{"float guarded()": 1},
+			files:  []string{"<source>", "test:lib/guarded.glsl"},
+		},
+		{
+			name:   "guard defined by the includer",
+			source: "#define GUARDED\n#include \"lib/guarded.glsl\"",
+			want:   map[string]int{"float guarded()": 0},
+			files:  []string{"<source>"},
+		},
+		{
+			name:   "included version is commented out",
+			source: "#version 410\n#include \"lib/version.glsl\"",
+			want:   map[string]int{"#version 410": 2, "// #version 410": 1},
+			files:  []string{"<source>", "test:lib/version.glsl"},
+		},
+		{
+			name:   "line directives move the numbering",
+			source: "#line 10\n#include \"lib/a.glsl\"",
+			want:   map[string]int{"\n#line 11 0\n": 1},
+			files:  []string{"<source>", "test:lib/a.glsl"},
+		},
+		{
+			name:   "cycle",
+			source: "#include \"lib/cycle_a.glsl\"",
+			err:    "shader include cycle: test:lib/cycle_a.glsl -> test:lib/cycle_b.glsl -> test:lib/cycle_a.glsl",
+		},
+		{
+			name:   "includes itself",
+			source: "#include \"lib/self.glsl\"",
+			err:    "shader include cycle: test:lib/self.glsl -> test:lib/self.glsl",
+		},
+		{
+			name:   "missing",
+			source: "void main() {}\n#include \"lib/missing.glsl\"",
+			err:    `<source>:2: shader include "lib/missing.glsl" not found`,
+		},
+		{
+			name:   "malformed",
+			source: "#include lib/a.glsl",
+			err:    "<source>:1: malformed include",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withShaderFiles(t, files)
+			src, err := PreprocessShader("", tt.source)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for s, n := range tt.want {
+				if got := strings.Count(src.Source, s); got != n {
+					t.Errorf("%q appears %v times, want %v in\n%v", s, got, n, src.Source)
+				}
+			}
+
+			if strings.Join(src.Files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("files %v, want %v", src.Files, tt.files)
+			}
+		})
+	}
+}
+
+// TestPreprocessShaderLines every line of the output maps back to the line it came from
+func TestPreprocessShaderLines(t *testing.T) {
+	withShaderFiles(t, map[string]string{
+		"a.glsl": "a1\n#include \"b.glsl\"\na3",
+		"b.glsl": "b1\nb2",
+	})
+
+	src, err := PreprocessShader("", "r1\nr2\n#include \"a.glsl\"\nr4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"r1": "<source>:1", "r2": "<source>:2", "r4": "<source>:4",
+		"a1": "test:a.glsl:1", "a3": "test:a.glsl:3",
+		"b1": "test:b.glsl:1", "b2": "test:b.glsl:2",
+	}
+
+	for text, location := range shaderLineLocations(t, src) {
+		if want[text] != location {
+			t.Errorf("%v is at %v, want %v", text, location, want[text])
+		}
+		delete(want, text)
+	}
+
+	for text := range want {
+		t.Errorf("%v missing from the output", text)
+	}
+}
+
+// shaderLineLocations file:line of each non directive line of preprocessed source,
+// following #line the way a compiler does
+func shaderLineLocations(t *testing.T, src *ShaderSource) map[string]string {
+	locations := map[string]string{}
+	line, file := 1, 0
+	for _, text := range strings.Split(src.Source, "\n") {
+		if directive, arg := parseDirective(text); directive == "line" {
+			fields := strings.Fields(arg)
+			var err error
+			if line, err = strconv.Atoi(fields[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(fields) > 1 {
+				if file, err = strconv.Atoi(fields[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			continue
+		}
+
+		if text != "" {
+			locations[text] = src.Files[file] + ":" + strconv.Itoa(line)
+		}
+		line++
+	}
+
+	return locations
+}
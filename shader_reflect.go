@@ -0,0 +1,396 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// ShaderVariable an active uniform or attribute of a linked program
+type ShaderVariable struct {
+	Name     string
+	Location int32
+	// Type gl type enum, e.g. gl.FLOAT_VEC2
+	Type uint32
+	// Size array length, 1 for non arrays
+	Size int32
+}
+
+// ShaderReflection active uniforms and attributes of a program, read once after linking
+type ShaderReflection struct {
+	Uniforms   map[string]ShaderVariable
+	Attributes map[string]ShaderVariable
+
+	// names already reported, misses are only logged once
+	reported map[string]bool
+}
+
+// NewShaderReflection enumerate the active uniforms and attributes of a linked program
+func NewShaderReflection(program uint32) *ShaderReflection {
+	reflection := &ShaderReflection{
+		Uniforms:   make(map[string]ShaderVariable),
+		Attributes: make(map[string]ShaderVariable),
+		reported:   make(map[string]bool),
+	}
+
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+	for i := uint32(0); i < uint32(count); i++ {
+		v := activeVariable(program, i, maxLength, gl.GetActiveUniform)
+		v.Location = gl.GetUniformLocation(program, gl.Str(v.Name+"\x00"))
+		reflection.add(reflection.Uniforms, v)
+	}
+
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLength)
+	for i := uint32(0); i < uint32(count); i++ {
+		v := activeVariable(program, i, maxLength, gl.GetActiveAttrib)
+		v.Location = gl.GetAttribLocation(program, gl.Str(v.Name+"\x00"))
+		reflection.add(reflection.Attributes, v)
+	}
+
+	return reflection
+}
+
+type getActiveFn func(program, index uint32, bufSize int32, length, size *int32, xtype *uint32, name *uint8)
+
+func activeVariable(program, index uint32, maxLength int32, getActive getActiveFn) ShaderVariable {
+	var length, size int32
+	var xtype uint32
+	name := make([]uint8, maxLength+1)
+	getActive(program, index, maxLength+1, &length, &size, &xtype, &name[0])
+
+	return ShaderVariable{
+		Name: string(name[:length]),
+		Type: xtype,
+		Size: size,
+	}
+}
+
+// add arrays are reported as name[0], make them reachable by name too
+func (self *ShaderReflection) add(variables map[string]ShaderVariable, v ShaderVariable) {
+	variables[v.Name] = v
+	if base := strings.TrimSuffix(v.Name, "[0]"); base != v.Name {
+		variables[base] = v
+	}
+}
+
+// report log a problem with a uniform the first time it happens
+func (self *ShaderReflection) report(name, format string, args ...interface{}) {
+	if self.reported[name] {
+		return
+	}
+
+	self.reported[name] = true
+	log.Printf(format, args...)
+}
+
+// Ignore uniforms that may be optimized out, setting them while inactive is not reported
+func (self *ShaderReflection) Ignore(names ...string) {
+	for _, name := range names {
+		if _, ok := self.Uniforms[name]; !ok {
+			self.reported[name] = true
+		}
+	}
+}
+
+// uniform kinds checked by the setters
+const (
+	uniformFloat = iota
+	uniformDouble
+	uniformInt
+	uniformUint
+	uniformBool
+	uniformFloatMatrix
+	uniformDoubleMatrix
+	// samplers and images, set with Uniform1i
+	uniformOpaque
+)
+
+type uniformKind struct {
+	kind, components int
+}
+
+var uniformKinds = map[uint32]uniformKind{
+	gl.FLOAT:      {uniformFloat, 1},
+	gl.FLOAT_VEC2: {uniformFloat, 2},
+	gl.FLOAT_VEC3: {uniformFloat, 3},
+	gl.FLOAT_VEC4: {uniformFloat, 4},
+
+	gl.DOUBLE:      {uniformDouble, 1},
+	gl.DOUBLE_VEC2: {uniformDouble, 2},
+	gl.DOUBLE_VEC3: {uniformDouble, 3},
+	gl.DOUBLE_VEC4: {uniformDouble, 4},
+
+	gl.INT:      {uniformInt, 1},
+	gl.INT_VEC2: {uniformInt, 2},
+	gl.INT_VEC3: {uniformInt, 3},
+	gl.INT_VEC4: {uniformInt, 4},
+
+	gl.UNSIGNED_INT:      {uniformUint, 1},
+	gl.UNSIGNED_INT_VEC2: {uniformUint, 2},
+	gl.UNSIGNED_INT_VEC3: {uniformUint, 3},
+	gl.UNSIGNED_INT_VEC4: {uniformUint, 4},
+
+	gl.BOOL:      {uniformBool, 1},
+	gl.BOOL_VEC2: {uniformBool, 2},
+	gl.BOOL_VEC3: {uniformBool, 3},
+	gl.BOOL_VEC4: {uniformBool, 4},
+
+	gl.FLOAT_MAT2:   {uniformFloatMatrix, 4},
+	gl.FLOAT_MAT3:   {uniformFloatMatrix, 9},
+	gl.FLOAT_MAT4:   {uniformFloatMatrix, 16},
+	gl.FLOAT_MAT2x3: {uniformFloatMatrix, 6},
+	gl.FLOAT_MAT2x4: {uniformFloatMatrix, 8},
+	gl.FLOAT_MAT3x2: {uniformFloatMatrix, 6},
+	gl.FLOAT_MAT3x4: {uniformFloatMatrix, 12},
+	gl.FLOAT_MAT4x2: {uniformFloatMatrix, 8},
+	gl.FLOAT_MAT4x3: {uniformFloatMatrix, 12},
+
+	gl.DOUBLE_MAT2:   {uniformDoubleMatrix, 4},
+	gl.DOUBLE_MAT3:   {uniformDoubleMatrix, 9},
+	gl.DOUBLE_MAT4:   {uniformDoubleMatrix, 16},
+	gl.DOUBLE_MAT2x3: {uniformDoubleMatrix, 6},
+	gl.DOUBLE_MAT2x4: {uniformDoubleMatrix, 8},
+	gl.DOUBLE_MAT3x2: {uniformDoubleMatrix, 6},
+	gl.DOUBLE_MAT3x4: {uniformDoubleMatrix, 12},
+	gl.DOUBLE_MAT4x2: {uniformDoubleMatrix, 8},
+	gl.DOUBLE_MAT4x3: {uniformDoubleMatrix, 12},
+}
+
+// accepts whether a setter for kind with n components can set a uniform of type xtype
+func (self uniformKind) accepts(xtype uint32) bool {
+	target, ok := uniformKinds[xtype]
+	if !ok {
+		target = uniformKind{uniformOpaque, 1}
+	}
+
+	if target.components != self.components {
+		return false
+	}
+
+	switch target.kind {
+	case self.kind:
+		return true
+	case uniformBool:
+		// bools may be set with any scalar setter
+		return self.kind == uniformFloat || self.kind == uniformInt || self.kind == uniformUint
+	case uniformOpaque:
+		return self.kind == uniformInt
+	}
+
+	return false
+}
+
+// uniformLocation cached location of a uniform, -1 when missing or a setter of the wrong type is used
+func (self Shader) uniformLocation(name string, kind, components int) int32 {
+	if self.Reflection == nil {
+		return gl.GetUniformLocation(*self.Program, gl.Str(name+"\x00"))
+	}
+
+	v, ok := self.Reflection.Uniforms[name]
+	if !ok {
+		v, ok = self.arrayElement(name)
+	}
+
+	if !ok {
+		self.Reflection.report(name, "shader: uniform %q is not active, misspelled or optimized out", name)
+		return -1
+	}
+
+	if !(uniformKind{kind, components}).accepts(v.Type) {
+		self.Reflection.report(name, "shader: uniform %q is %v, set with the wrong type", name, uniformTypeName(v.Type))
+		return -1
+	}
+
+	return v.Location
+}
+
+// arrayElement look up an element like "arr[2]" of an array uniform, only the first element
+// is reflected, other elements are cached the first time they are set
+func (self Shader) arrayElement(name string) (ShaderVariable, bool) {
+	i := strings.LastIndexByte(name, '[')
+	if i < 0 || !strings.HasSuffix(name, "]") {
+		return ShaderVariable{}, false
+	}
+
+	array, ok := self.Reflection.Uniforms[name[:i]]
+	index, err := strconv.Atoi(name[i+1 : len(name)-1])
+	if !ok || err != nil || index < 0 || index >= int(array.Size) {
+		return ShaderVariable{}, false
+	}
+
+	location := gl.GetUniformLocation(*self.Program, gl.Str(name+"\x00"))
+	if location < 0 {
+		return ShaderVariable{}, false
+	}
+
+	v := ShaderVariable{Name: name, Location: location, Type: array.Type, Size: array.Size - int32(index)}
+	self.Reflection.Uniforms[name] = v
+	return v, true
+}
+
+func uniformTypeName(xtype uint32) string {
+	names := map[uint32]string{
+		gl.FLOAT: "float", gl.FLOAT_VEC2: "vec2", gl.FLOAT_VEC3: "vec3", gl.FLOAT_VEC4: "vec4",
+		gl.DOUBLE: "double", gl.DOUBLE_VEC2: "dvec2", gl.DOUBLE_VEC3: "dvec3", gl.DOUBLE_VEC4: "dvec4",
+		gl.INT: "int", gl.INT_VEC2: "ivec2", gl.INT_VEC3: "ivec3", gl.INT_VEC4: "ivec4",
+		gl.UNSIGNED_INT: "uint", gl.UNSIGNED_INT_VEC2: "uvec2", gl.UNSIGNED_INT_VEC3: "uvec3", gl.UNSIGNED_INT_VEC4: "uvec4",
+		gl.BOOL: "bool", gl.BOOL_VEC2: "bvec2", gl.BOOL_VEC3: "bvec3", gl.BOOL_VEC4: "bvec4",
+		gl.FLOAT_MAT2: "mat2", gl.FLOAT_MAT3: "mat3", gl.FLOAT_MAT4: "mat4",
+	}
+
+	if name, ok := names[xtype]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("type 0x%x", xtype)
+}
+
+// UniformValue set a uniform from a loosely typed value, a number, bool or list of them as decoded from json.
+// The setter is picked from the uniform's type, lists set arrays and vectors.
+func (self Shader) UniformValue(name string, value interface{}) Shader {
+	if self.Reflection == nil {
+		log.Printf("shader: uniform %q can not be set by value without reflection", name)
+		return self
+	}
+
+	v, ok := self.Reflection.Uniforms[name]
+	if !ok {
+		v, ok = self.arrayElement(name)
+	}
+
+	if !ok {
+		self.Reflection.report(name, "shader: uniform %q is not active, misspelled or optimized out", name)
+		return self
+	}
+
+	values, err := uniformValues(value)
+	if err != nil {
+		self.Reflection.report(name, "shader: uniform %q: %v", name, err)
+		return self
+	}
+
+	kind, ok := uniformKinds[v.Type]
+	if !ok {
+		kind = uniformKind{uniformOpaque, 1}
+	}
+
+	if len(values) == 0 || len(values)%kind.components != 0 {
+		self.Reflection.report(name, "shader: uniform %q is %v, %v values can't set it", name, uniformTypeName(v.Type), len(values))
+		return self
+	}
+
+	program, location := *self.Program, v.Location
+	count := int32(len(values) / kind.components)
+	switch kind.kind {
+	case uniformFloat:
+		f := make([]float32, len(values))
+		for i, x := range values {
+			f[i] = float32(x)
+		}
+
+		[]func(uint32, int32, int32, *float32){
+			gl.ProgramUniform1fv, gl.ProgramUniform2fv, gl.ProgramUniform3fv, gl.ProgramUniform4fv,
+		}[kind.components-1](program, location, count, &f[0])
+	case uniformDouble:
+		[]func(uint32, int32, int32, *float64){
+			gl.ProgramUniform1dv, gl.ProgramUniform2dv, gl.ProgramUniform3dv, gl.ProgramUniform4dv,
+		}[kind.components-1](program, location, count, &values[0])
+	case uniformUint:
+		u := make([]uint32, len(values))
+		for i, x := range values {
+			u[i] = uint32(x)
+		}
+
+		[]func(uint32, int32, int32, *uint32){
+			gl.ProgramUniform1uiv, gl.ProgramUniform2uiv, gl.ProgramUniform3uiv, gl.ProgramUniform4uiv,
+		}[kind.components-1](program, location, count, &u[0])
+	case uniformInt, uniformBool, uniformOpaque:
+		n := make([]int32, len(values))
+		for i, x := range values {
+			n[i] = int32(x)
+		}
+
+		[]func(uint32, int32, int32, *int32){
+			gl.ProgramUniform1iv, gl.ProgramUniform2iv, gl.ProgramUniform3iv, gl.ProgramUniform4iv,
+		}[kind.components-1](program, location, count, &n[0])
+	case uniformFloatMatrix:
+		f := make([]float32, len(values))
+		for i, x := range values {
+			f[i] = float32(x)
+		}
+
+		setters := map[uint32]func(uint32, int32, int32, bool, *float32){
+			gl.FLOAT_MAT2: gl.ProgramUniformMatrix2fv,
+			gl.FLOAT_MAT3: gl.ProgramUniformMatrix3fv,
+			gl.FLOAT_MAT4: gl.ProgramUniformMatrix4fv,
+		}
+
+		setter, ok := setters[v.Type]
+		if !ok {
+			self.Reflection.report(name, "shader: uniform %q is %v, only square matrices can be set by value", name, uniformTypeName(v.Type))
+			return self
+		}
+
+		setter(program, location, count, false, &f[0])
+	default:
+		self.Reflection.report(name, "shader: uniform %q is %v, it can't be set by value", name, uniformTypeName(v.Type))
+	}
+
+	return self
+}
+
+// uniformValues flatten numbers, bools and lists of them
+func uniformValues(value interface{}) ([]float64, error) {
+	switch x := value.(type) {
+	case float64:
+		return []float64{x}, nil
+	case float32:
+		return []float64{float64(x)}, nil
+	case int:
+		return []float64{float64(x)}, nil
+	case int32:
+		return []float64{float64(x)}, nil
+	case bool:
+		if x {
+			return []float64{1}, nil
+		}
+
+		return []float64{0}, nil
+	case []float64:
+		return x, nil
+	case []float32:
+		values := make([]float64, len(x))
+		for i, f := range x {
+			values[i] = float64(f)
+		}
+
+		return values, nil
+	case []int32:
+		values := make([]float64, len(x))
+		for i, n := range x {
+			values[i] = float64(n)
+		}
+
+		return values, nil
+	case []interface{}:
+		values := make([]float64, 0, len(x))
+		for _, item := range x {
+			v, err := uniformValues(item)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, v...)
+		}
+
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("can't set a uniform to %T", value)
+}
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type HotShader struct {
+	VertFilename string
+	VertSource   string
+
+	FragFilename string
+	FragSource   string
+
+	// Dependencies files on disk the shader was built from, includes included
+	Dependencies []string
+	// Err error from the last compile, nil once it succeeds and the new program is in use
+	Err error
+
+	BufferObject BufferObject
+	*Shader
+}
+
+// Compile preprocess sources read from the watched files, includes resolve next to them
+func (self *HotShader) Compile(vert, frag string) error {
+	vs, err := PreprocessShader(self.VertFilename, vert)
+	if err != nil {
+		return err
+	}
+
+	fs, err := PreprocessShader(self.FragFilename, frag)
+	if err != nil {
+		return err
+	}
+
+	// track includes even if compiling fails, fixing one should trigger a rebuild
+	self.Dependencies = append(vs.Dependencies, fs.Dependencies...)
+	return self.Shader.CompileSources(vs, fs, self.BufferObject)
+}
+
+// ShaderWatcher recompiles shaders when the files they were built from change.
+// Directories are watched rather than files so editors that save by renaming a temp file are seen.
+type ShaderWatcher struct {
+	*fsnotify.Watcher
+
+	// Changes batches of changed files, pass them to Handle on the render thread
+	Changes chan []string
+
+	// map of watched files to the shaders built from them
+	WatchedFiles map[string][]*HotShader
+	shaders      []*HotShader
+	dirs         map[string]bool
+	// debounce time events must settle for before shaders are recompiled, fixed once watching
+	debounce time.Duration
+
+	// Diagnostics of every shader whose last compile failed, empty once they all succeed.
+	// Failed shaders keep drawing with their last good program.
+	Diagnostics []ShaderDiagnostic
+	// OnDiagnostics called after every compile, e.g. to forward diagnostics to an editor or ShaderErrorOverlay.Update
+	OnDiagnostics func(diagnostics []ShaderDiagnostic)
+}
+
+// DefaultShaderDebounce time file events settle for before shaders are recompiled
+const DefaultShaderDebounce = 100 * time.Millisecond
+
+// NewShaderWatcher start watching, changes are batched once events have been quiet for debounce,
+// DefaultShaderDebounce when 0
+func NewShaderWatcher(debounce time.Duration) *ShaderWatcher {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		panic(err)
+	}
+
+	if debounce <= 0 {
+		debounce = DefaultShaderDebounce
+	}
+
+	sw := &ShaderWatcher{
+		Watcher:  watcher,
+		Changes:  make(chan []string, 1),
+		debounce: debounce,
+
+		WatchedFiles: make(map[string][]*HotShader),
+		dirs:         make(map[string]bool),
+	}
+
+	go sw.watch()
+	return sw
+}
+
+// watch coalesce file events into batches once they have been quiet for debounce
+func (self *ShaderWatcher) watch() {
+	defer close(self.Changes)
+
+	pending := make(map[string]bool)
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-self.Watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+
+			pending[AbsPath(event.Name)] = true
+			settled = time.After(self.debounce)
+		case err, ok := <-self.Watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Println("shader watcher:", err)
+		case <-settled:
+			files := make([]string, 0, len(pending))
+			for f := range pending {
+				files = append(files, f)
+			}
+
+			sort.Strings(files)
+			pending = make(map[string]bool)
+			settled = nil
+
+			self.Changes <- files
+		}
+	}
+}
+
+func (self *ShaderWatcher) Add(shader *Shader, vertFilename, fragFilename string, bo BufferObject) {
+	log.Println("watching: ", vertFilename)
+	log.Println("watching: ", fragFilename)
+
+	hs := &HotShader{
+		VertFilename: filepath.Clean(vertFilename),
+		FragFilename: filepath.Clean(fragFilename),
+
+		BufferObject: bo,
+		Shader:       shader,
+	}
+
+	self.shaders = append(self.shaders, hs)
+
+	// attempt compile, errors are reported not fatal
+	self.report(self.compile(hs))
+}
+
+// compile read a shader's files and rebuild it, then watch whatever it now depends on
+func (self *ShaderWatcher) compile(hs *HotShader) (err error) {
+	defer func() {
+		hs.Err = err
+		self.index()
+	}()
+
+	vert, err := os.ReadFile(hs.VertFilename)
+	if err != nil {
+		hs.Dependencies = []string{hs.VertFilename, hs.FragFilename}
+		return err
+	}
+
+	frag, err := os.ReadFile(hs.FragFilename)
+	if err != nil {
+		hs.Dependencies = []string{hs.VertFilename, hs.FragFilename}
+		return err
+	}
+
+	if err = hs.Compile(string(vert), string(frag)); err != nil {
+		return err
+	}
+
+	hs.VertSource = string(vert)
+	hs.FragSource = string(frag)
+	return nil
+}
+
+// index rebuild the file to shader map and watch any new directories
+func (self *ShaderWatcher) index() {
+	self.WatchedFiles = make(map[string][]*HotShader)
+	for _, hs := range self.shaders {
+		for _, dep := range append([]string{hs.VertFilename, hs.FragFilename}, hs.Dependencies...) {
+			file := AbsPath(dep)
+			if watched := self.WatchedFiles[file]; len(watched) > 0 && watched[len(watched)-1] == hs {
+				continue
+			}
+
+			self.WatchedFiles[file] = append(self.WatchedFiles[file], hs)
+
+			dir := filepath.Dir(file)
+			if self.dirs[dir] {
+				continue
+			}
+
+			if err := self.Watcher.Add(dir); err != nil {
+				log.Println("shader watcher:", err)
+				continue
+			}
+
+			self.dirs[dir] = true
+		}
+	}
+}
+
+// Handle recompile every shader depending on the changed files.
+// Returns the shaders rebuilt and the first error.
+func (self *ShaderWatcher) Handle(files []string, ok bool) ([]*HotShader, error) {
+	if !ok {
+		return nil, fmt.Errorf("ShaderWatcher.Handle: watcher closed")
+	}
+
+	affected := make([]*HotShader, 0)
+	seen := make(map[*HotShader]bool)
+	for _, f := range files {
+		for _, hs := range self.WatchedFiles[AbsPath(f)] {
+			if !seen[hs] {
+				seen[hs] = true
+				affected = append(affected, hs)
+			}
+		}
+	}
+
+	if len(affected) == 0 {
+		return nil, nil
+	}
+
+	for _, f := range files {
+		if len(self.WatchedFiles[AbsPath(f)]) > 0 {
+			log.Println("modified file:", f)
+		}
+	}
+
+	compiled := make([]*HotShader, 0, len(affected))
+	errs := make([]error, 0)
+	for _, hs := range affected {
+		if err := self.compile(hs); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		compiled = append(compiled, hs)
+	}
+
+	self.report(errs...)
+	if len(errs) > 0 {
+		return compiled, errs[0]
+	}
+
+	return compiled, nil
+}
+
+// report log the diagnostics of a compile, then collect those of every failing shader for OnDiagnostics
+func (self *ShaderWatcher) report(errs ...error) {
+	for _, err := range errs {
+		for _, d := range ShaderErrorDiagnostics(err) {
+			log.Println(d)
+		}
+	}
+
+	self.Diagnostics = nil
+	for _, hs := range self.shaders {
+		self.Diagnostics = append(self.Diagnostics, ShaderErrorDiagnostics(hs.Err)...)
+	}
+
+	if self.OnDiagnostics != nil {
+		self.OnDiagnostics(self.Diagnostics)
+	}
+}
+
+// AbsPath absolute clean path of a file, how watched files and dependencies are compared
+func AbsPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(name)
+}
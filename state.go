@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// glUnknown a binding the tracker has not seen, the next bind always reaches gl
+const glUnknown = ^uint32(0)
+
+// maxTrackedUnits texture units tracked, higher units are always bound
+const maxTrackedUnits = 32
+
+// GLState tracks the framebuffer, renderbuffer, texture unit, program and vertex array
+// bindings of the current context. Binds that would change nothing skip gl, and passes
+// that bind their own targets save and restore the caller's with
+//
+//	defer State.Push().Pop()
+//
+// Build with -tags gldebug to check every tracked bind against glGet.
+type GLState struct {
+	glBindings
+	stack []glBindings
+}
+
+type glBindings struct {
+	drawFramebuffer uint32
+	readFramebuffer uint32
+	renderbuffer    uint32
+	program         uint32
+	vertexArray     uint32
+	activeTexture   uint32
+	textures        [maxTrackedUnits]uint32
+}
+
+// State bindings of the current context, everything in the engine binds through it
+var State = NewGLState()
+
+func NewGLState() *GLState {
+	state := &GLState{}
+	state.Reset()
+	return state
+}
+
+// Reset forget every binding and saved frame, after a new context is made current
+// or gl was called behind the tracker's back
+func (self *GLState) Reset() {
+	self.glBindings = glBindings{
+		drawFramebuffer: glUnknown,
+		readFramebuffer: glUnknown,
+		renderbuffer:    glUnknown,
+		program:         glUnknown,
+		vertexArray:     glUnknown,
+		activeTexture:   glUnknown,
+	}
+
+	for i := range self.textures {
+		self.textures[i] = glUnknown
+	}
+
+	self.stack = nil
+}
+
+// Push save the bindings, returns the state for defer State.Push().Pop()
+func (self *GLState) Push() *GLState {
+	self.stack = append(self.stack, self.glBindings)
+	return self
+}
+
+// Pop rebind what changed since the matching Push
+func (self *GLState) Pop() {
+	if len(self.stack) == 0 {
+		panic("GLState.Pop without a matching Push")
+	}
+
+	saved := self.stack[len(self.stack)-1]
+	self.stack = self.stack[:len(self.stack)-1]
+
+	if saved.drawFramebuffer == saved.readFramebuffer {
+		self.BindFramebuffer(gl.FRAMEBUFFER, saved.drawFramebuffer)
+	} else {
+		self.BindFramebuffer(gl.DRAW_FRAMEBUFFER, saved.drawFramebuffer)
+		self.BindFramebuffer(gl.READ_FRAMEBUFFER, saved.readFramebuffer)
+	}
+
+	self.BindRenderbuffer(saved.renderbuffer)
+	self.UseProgram(saved.program)
+	self.BindVertexArray(saved.vertexArray)
+
+	for i, handle := range saved.textures {
+		if handle != glUnknown && handle != self.textures[i] {
+			self.BindTexture(gl.TEXTURE0+uint32(i), handle)
+		}
+	}
+
+	self.ActiveTexture(saved.activeTexture)
+}
+
+// BindFramebuffer bind to gl.FRAMEBUFFER, gl.DRAW_FRAMEBUFFER or gl.READ_FRAMEBUFFER
+func (self *GLState) BindFramebuffer(target, handle uint32) {
+	if handle == glUnknown {
+		return
+	}
+
+	self.check()
+	switch target {
+	case gl.FRAMEBUFFER:
+		if self.drawFramebuffer == handle && self.readFramebuffer == handle {
+			return
+		}
+
+		self.drawFramebuffer, self.readFramebuffer = handle, handle
+	case gl.DRAW_FRAMEBUFFER:
+		if self.drawFramebuffer == handle {
+			return
+		}
+
+		self.drawFramebuffer = handle
+	case gl.READ_FRAMEBUFFER:
+		if self.readFramebuffer == handle {
+			return
+		}
+
+		self.readFramebuffer = handle
+	default:
+		panic(fmt.Sprintf("GLState.BindFramebuffer: unknown target 0x%x", target))
+	}
+
+	gl.BindFramebuffer(target, handle)
+}
+
+func (self *GLState) BindRenderbuffer(handle uint32) {
+	if handle == glUnknown || handle == self.renderbuffer {
+		return
+	}
+
+	self.check()
+	self.renderbuffer = handle
+	gl.BindRenderbuffer(gl.RENDERBUFFER, handle)
+}
+
+func (self *GLState) UseProgram(handle uint32) {
+	if handle == glUnknown || handle == self.program {
+		return
+	}
+
+	self.check()
+	self.program = handle
+	gl.UseProgram(handle)
+}
+
+func (self *GLState) BindVertexArray(handle uint32) {
+	if handle == glUnknown || handle == self.vertexArray {
+		return
+	}
+
+	self.check()
+	self.vertexArray = handle
+	gl.BindVertexArray(handle)
+}
+
+// ActiveTexture select a unit, gl.TEXTURE0 and up
+func (self *GLState) ActiveTexture(unit uint32) {
+	if unit == glUnknown || unit == self.activeTexture {
+		return
+	}
+
+	self.check()
+	self.activeTexture = unit
+	gl.ActiveTexture(unit)
+}
+
+// BindTexture bind a 2d texture to a unit, leaving that unit active
+func (self *GLState) BindTexture(unit, handle uint32) {
+	self.ActiveTexture(unit)
+
+	i := unit - gl.TEXTURE0
+	if i >= maxTrackedUnits {
+		gl.BindTexture(gl.TEXTURE_2D, handle)
+		return
+	}
+
+	if self.textures[i] == handle {
+		return
+	}
+
+	self.check()
+	self.textures[i] = handle
+	gl.BindTexture(gl.TEXTURE_2D, handle)
+}
+
+// DeleteFramebuffers delete framebuffers, gl unbinds them so bindings to them become 0
+func (self *GLState) DeleteFramebuffers(handles ...uint32) {
+	if len(handles) == 0 {
+		return
+	}
+
+	gl.DeleteFramebuffers(int32(len(handles)), &handles[0])
+	self.forget(handles, func(b *glBindings) []*uint32 {
+		return []*uint32{&b.drawFramebuffer, &b.readFramebuffer}
+	})
+}
+
+func (self *GLState) DeleteRenderbuffers(handles ...uint32) {
+	if len(handles) == 0 {
+		return
+	}
+
+	gl.DeleteRenderbuffers(int32(len(handles)), &handles[0])
+	self.forget(handles, func(b *glBindings) []*uint32 {
+		return []*uint32{&b.renderbuffer}
+	})
+}
+
+func (self *GLState) DeleteTextures(handles ...uint32) {
+	if len(handles) == 0 {
+		return
+	}
+
+	gl.DeleteTextures(int32(len(handles)), &handles[0])
+	self.forget(handles, func(b *glBindings) []*uint32 {
+		units := make([]*uint32, len(b.textures))
+		for i := range b.textures {
+			units[i] = &b.textures[i]
+		}
+
+		return units
+	})
+}
+
+func (self *GLState) DeleteVertexArrays(handles ...uint32) {
+	if len(handles) == 0 {
+		return
+	}
+
+	gl.DeleteVertexArrays(int32(len(handles)), &handles[0])
+	self.forget(handles, func(b *glBindings) []*uint32 {
+		return []*uint32{&b.vertexArray}
+	})
+}
+
+// forget deleted objects in the current and every saved frame, gl may reuse their handles
+func (self *GLState) forget(handles []uint32, bindings func(*glBindings) []*uint32) {
+	frames := []*glBindings{&self.glBindings}
+	for i := range self.stack {
+		frames = append(frames, &self.stack[i])
+	}
+
+	for _, frame := range frames {
+		for _, binding := range bindings(frame) {
+			for _, handle := range handles {
+				if *binding == handle {
+					*binding = 0
+				}
+			}
+		}
+	}
+}
+
+// Verify compare the tracked bindings with glGet, unknown bindings are skipped
+func (self *GLState) Verify() error {
+	bindings := []struct {
+		name    string
+		pname   uint32
+		tracked uint32
+	}{
+		{"draw framebuffer", gl.DRAW_FRAMEBUFFER_BINDING, self.drawFramebuffer},
+		{"read framebuffer", gl.READ_FRAMEBUFFER_BINDING, self.readFramebuffer},
+		{"renderbuffer", gl.RENDERBUFFER_BINDING, self.renderbuffer},
+		{"program", gl.CURRENT_PROGRAM, self.program},
+		{"vertex array", gl.VERTEX_ARRAY_BINDING, self.vertexArray},
+	}
+
+	for _, b := range bindings {
+		if b.tracked == glUnknown {
+			continue
+		}
+
+		var bound int32
+		gl.GetIntegerv(b.pname, &bound)
+		if uint32(bound) != b.tracked {
+			return fmt.Errorf("gl state: %v is %v, tracked %v", b.name, bound, b.tracked)
+		}
+	}
+
+	var active int32
+	gl.GetIntegerv(gl.ACTIVE_TEXTURE, &active)
+	if self.activeTexture != glUnknown && uint32(active) != self.activeTexture {
+		return fmt.Errorf("gl state: active texture is unit %v, tracked unit %v",
+			uint32(active)-gl.TEXTURE0, self.activeTexture-gl.TEXTURE0)
+	}
+
+	var err error
+	for i, tracked := range self.textures {
+		if tracked == glUnknown {
+			continue
+		}
+
+		var bound int32
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+		gl.GetIntegerv(gl.TEXTURE_BINDING_2D, &bound)
+		if uint32(bound) != tracked && err == nil {
+			err = fmt.Errorf("gl state: texture unit %v is %v, tracked %v", i, bound, tracked)
+		}
+	}
+
+	gl.ActiveTexture(uint32(active))
+	return err
+}
+
+// check verify before every tracked bind in debug builds
+func (self *GLState) check() {
+	if !debugGLState {
+		return
+	}
+
+	if err := self.Verify(); err != nil {
+		panic(err)
+	}
+}
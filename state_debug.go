@@ -0,0 +1,7 @@
+//go:build gldebug
+// +build gldebug
+
+package engine
+
+// debugGLState check tracked bindings against glGet before every bind
+const debugGLState = true
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Surface is what programs draw to and query input from.
+// A *glfw.Window satisfies it, as does a HeadlessSurface.
+type Surface interface {
+	GetFramebufferSize() (width, height int)
+	GetCursorPos() (x, y float64)
+	GetMouseButton(button glfw.MouseButton) glfw.Action
+	GetInputMode(mode glfw.InputMode) int
+	SetScrollCallback(cbfun glfw.ScrollCallback) (previous glfw.ScrollCallback)
+	SetCursorPosCallback(cbfun glfw.CursorPosCallback) (previous glfw.CursorPosCallback)
+	SetTitle(title string)
+	ShouldClose() bool
+	SwapBuffers()
+}
+
+// ScreenFramebuffer is the framebuffer programs present to,
+// 0 for a window and an offscreen framebuffer when headless
+var ScreenFramebuffer uint32 = 0
+
+// HeadlessInternalFormat of headless color buffers, a float format such as gl.RGBA32F keeps
+// values outside 0 to 1 and makes Renderer.Capture save hdr images
+var HeadlessInternalFormat int32 = gl.RGBA8
+
+// HeadlessSurface renders into an offscreen framebuffer for a fixed number of frames
+type HeadlessSurface struct {
+	Width, Height int
+	Frames        int
+	Frame         int
+
+	Texture *Texture
+
+	rbo uint32
+	*Framebuffer
+}
+
+// NewHeadlessSurface create an offscreen surface and make it the screen framebuffer.
+// Requires a current OpenGL context.
+func NewHeadlessSurface(width, height, frames int) *HeadlessSurface {
+	hs := &HeadlessSurface{
+		Width:  width,
+		Height: height,
+		Frames: frames,
+
+		Framebuffer: NewFramebuffer(),
+	}
+
+	State.BindFramebuffer(gl.FRAMEBUFFER, hs.Framebuffer.Handle)
+
+	// color attachment
+	hs.Texture = NewTexture(width, height, HeadlessInternalFormat)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, hs.Texture.Handle, 0)
+
+	// depth stencil attachment
+	gl.GenRenderbuffers(1, &hs.rbo)
+	State.BindRenderbuffer(hs.rbo)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH24_STENCIL8, int32(width), int32(height))
+	State.BindRenderbuffer(0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, hs.rbo)
+	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
+		panic("ERROR: Framebuffer is not complete")
+	}
+
+	ScreenFramebuffer = hs.Framebuffer.Handle
+	return hs
+}
+
+func (self *HeadlessSurface) GetFramebufferSize() (width, height int) {
+	return self.Width, self.Height
+}
+
+// GetCursorPos headless surfaces have no cursor, report the center
+func (self *HeadlessSurface) GetCursorPos() (x, y float64) {
+	return float64(self.Width) / 2, float64(self.Height) / 2
+}
+
+func (self *HeadlessSurface) GetMouseButton(button glfw.MouseButton) glfw.Action {
+	return glfw.Release
+}
+
+func (self *HeadlessSurface) GetInputMode(mode glfw.InputMode) int {
+	return glfw.CursorNormal
+}
+
+func (self *HeadlessSurface) SetScrollCallback(cbfun glfw.ScrollCallback) (previous glfw.ScrollCallback) {
+	return nil
+}
+
+func (self *HeadlessSurface) SetCursorPosCallback(cbfun glfw.CursorPosCallback) (previous glfw.CursorPosCallback) {
+	return nil
+}
+
+func (self *HeadlessSurface) SetTitle(title string) {}
+
+func (self *HeadlessSurface) ShouldClose() bool {
+	return self.Frame >= self.Frames
+}
+
+// SwapBuffers waits on the frame to finish and advances the frame count
+func (self *HeadlessSurface) SwapBuffers() {
+	gl.Finish()
+	self.Frame++
+}
+
+func (self *HeadlessSurface) Cleanup() {
+	if ScreenFramebuffer == self.Framebuffer.Handle {
+		ScreenFramebuffer = 0
+	}
+
+	State.DeleteRenderbuffers(self.rbo)
+	State.DeleteFramebuffers(self.Framebuffer.Handle)
+	State.DeleteTextures(self.Texture.Handle)
+}
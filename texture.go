@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"image"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
@@ -9,21 +10,121 @@ import (
 
 type Texture struct {
 	Handle uint32
-	Image  *image.RGBA
+	// Image cpu copy of RGBA8 textures, nil for other formats
+	Image *image.RGBA
+
+	Width, Height int
+	Format        TextureFormat
+	Options       TextureOptions
+
+	// mipmapped whether the mipmaps match level 0
+	mipmapped bool
+}
+
+// TextureOptions how a texture is sampled. Zero fields take the DefaultTextureOptions value.
+type TextureOptions struct {
+	// MinFilter gl.NEAREST, gl.LINEAR or a mipmap filter such as gl.LINEAR_MIPMAP_LINEAR
+	MinFilter int32
+	MagFilter int32
+	// WrapS and WrapT gl.REPEAT, gl.MIRRORED_REPEAT, gl.CLAMP_TO_EDGE or gl.CLAMP_TO_BORDER
+	WrapS int32
+	WrapT int32
+	// BorderColor sampled outside gl.CLAMP_TO_BORDER textures
+	BorderColor [4]float32
+	// Anisotropy max anisotropic filtering samples, clamped to what the driver supports, 0 leaves it off.
+	// Ignored without GL_EXT_texture_filter_anisotropic or GL_ARB_texture_filter_anisotropic.
+	Anisotropy float32
+	// Mipmaps generate mipmaps on upload and resize, call GenerateMipmaps after drawing into the texture
+	Mipmaps bool
+	// FlipY image files are loaded with their top row at v = 1
+	FlipY bool
 }
 
-var LastActiveTexture0 uint32
+var (
+	// DefaultTextureOptions texel exact and tiling, for cellular automata and other simulations
+	DefaultTextureOptions = TextureOptions{MinFilter: gl.NEAREST, MagFilter: gl.NEAREST, WrapS: gl.REPEAT, WrapT: gl.REPEAT}
+	// LinearTextureOptions smooth and clamped, for post processing
+	LinearTextureOptions = TextureOptions{MinFilter: gl.LINEAR, MagFilter: gl.LINEAR, WrapS: gl.CLAMP_TO_EDGE, WrapT: gl.CLAMP_TO_EDGE}
+	// MipmapTextureOptions for images drawn scaled down
+	MipmapTextureOptions = TextureOptions{MinFilter: gl.LINEAR_MIPMAP_LINEAR, MagFilter: gl.LINEAR, WrapS: gl.REPEAT, WrapT: gl.REPEAT, Mipmaps: true}
+)
+
+// textureOptions the first of options, or the defaults
+func textureOptions(options []TextureOptions) TextureOptions {
+	if len(options) == 0 {
+		return DefaultTextureOptions
+	}
+
+	opts := options[0]
+	if opts.MinFilter == 0 {
+		opts.MinFilter = DefaultTextureOptions.MinFilter
+	}
+
+	if opts.MagFilter == 0 {
+		opts.MagFilter = DefaultTextureOptions.MagFilter
+	}
+
+	if opts.WrapS == 0 {
+		opts.WrapS = DefaultTextureOptions.WrapS
+	}
+
+	if opts.WrapT == 0 {
+		opts.WrapT = DefaultTextureOptions.WrapT
+	}
+
+	return opts
+}
+
+// apply set the sampling parameters of the texture bound to the active unit
+func (self TextureOptions) apply() {
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, self.WrapS)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, self.WrapT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, self.MinFilter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, self.MagFilter)
+	gl.TexParameterfv(gl.TEXTURE_2D, gl.TEXTURE_BORDER_COLOR, &self.BorderColor[0])
+
+	if max := maxAnisotropy(); self.Anisotropy > 0 && max > 0 {
+		samples := self.Anisotropy
+		if samples > max {
+			samples = max
+		}
+
+		// 1 is off, lower values are invalid
+		if samples < 1 {
+			samples = 1
+		}
+
+		gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAX_ANISOTROPY, samples)
+	}
+}
+
+var anisotropy struct {
+	checked bool
+	max     float32
+}
+
+// maxAnisotropy most anisotropic filtering samples the driver supports, 0 without the extension.
+// It is core only from gl 4.6, querying it on 4.1 without the extension is an error.
+func maxAnisotropy() float32 {
+	if !anisotropy.checked {
+		anisotropy.checked = true
+		if hasExtension("GL_EXT_texture_filter_anisotropic") || hasExtension("GL_ARB_texture_filter_anisotropic") {
+			gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &anisotropy.max)
+		}
+	}
+
+	return anisotropy.max
+}
+
+// LoadTexture upload an image, sampled with DefaultTextureOptions unless options are given
+func LoadTexture(rgba *image.RGBA, options ...TextureOptions) *Texture {
+	opts := textureOptions(options)
 
-func LoadTexture(rgba *image.RGBA) *Texture {
 	var texture uint32
 	gl.GenTextures(1, &texture)
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
-	defer gl.BindTexture(gl.TEXTURE_2D, LastActiveTexture0)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, texture)
+	opts.apply()
 	gl.TexImage2D(
 		gl.TEXTURE_2D,
 		0,
@@ -35,14 +136,188 @@ func LoadTexture(rgba *image.RGBA) *Texture {
 		gl.UNSIGNED_BYTE,
 		gl.Ptr(rgba.Pix))
 
+	if opts.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+
 	// bind back 0 texture
 	return &Texture{
-		texture,
-		rgba,
+		Handle:    texture,
+		Image:     rgba,
+		Width:     rgba.Rect.Dx(),
+		Height:    rgba.Rect.Dy(),
+		Format:    FormatRGBA8,
+		Options:   opts,
+		mipmapped: opts.Mipmaps,
+	}
+}
+
+// NewTexture allocate an empty texture with an internal format such as gl.RGBA8 or gl.RGBA32F,
+// see TextureFormat for those supported. Sampled with DefaultTextureOptions unless options are given.
+func NewTexture(width, height int, internalFormat int32, options ...TextureOptions) *Texture {
+	format := mustTextureFormat(internalFormat)
+	opts := textureOptions(options)
+
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, texture)
+	opts.apply()
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		format.Internal,
+		int32(width),
+		int32(height),
+		0,
+		format.Format,
+		format.Type,
+		nil)
+
+	tex := &Texture{
+		Handle:  texture,
+		Width:   width,
+		Height:  height,
+		Format:  format,
+		Options: opts,
 	}
+
+	if format == FormatRGBA8 {
+		tex.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	return tex
 }
 
-func (self *Texture) Resize(width, height int) *Texture {
+// LoadTextureData allocate a texture and upload data to it, see Upload
+func LoadTextureData(width, height int, internalFormat int32, data interface{}, options ...TextureOptions) (*Texture, error) {
+	tex := NewTexture(width, height, internalFormat, options...)
+	if err := tex.Upload(data); err != nil {
+		State.DeleteTextures(tex.Handle)
+		return nil, err
+	}
+
+	return tex, nil
+}
+
+// Upload replace the whole texture. data is an image the size of the texture, or a slice
+// of tightly packed texels with the texture's channels: []uint8, []uint16, []float32,
+// []uint32 or []int32. []uint16 holds half floats for float formats, see Float16.
+func (self *Texture) Upload(data interface{}) error {
+	format, typ, pixels, count, err := self.Format.transfer(data)
+	if err != nil {
+		return err
+	}
+
+	if count != self.Width*self.Height {
+		return fmt.Errorf("texture data has %v texels, %vx%v needs %v", count, self.Width, self.Height, self.Width*self.Height)
+	}
+
+	if self.Format == FormatRGBA8 {
+		// other data leaves no cpu copy to resize from
+		self.Image, _ = data.(*image.RGBA)
+	}
+
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.Handle)
+	// rows of single channel bytes are not 4 byte aligned
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(
+		gl.TEXTURE_2D,
+		0,
+		0, 0,
+		int32(self.Width),
+		int32(self.Height),
+		format,
+		typ,
+		gl.Ptr(pixels))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+
+	self.mipmapped = false
+	self.SetOptions(self.Options)
+	return nil
+}
+
+// SetOptions change how the texture is sampled, generating mipmaps if they are now needed
+func (self *Texture) SetOptions(options TextureOptions) *Texture {
+	self.Options = textureOptions([]TextureOptions{options})
+
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.Handle)
+	self.Options.apply()
+	if self.Options.Mipmaps && !self.mipmapped {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+		self.mipmapped = true
+	}
+
+	return self
+}
+
+// GenerateMipmaps rebuild the mipmaps from level 0, e.g. after drawing into the texture
+func (self *Texture) GenerateMipmaps() *Texture {
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.Handle)
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+
+	self.mipmapped = true
+	return self
+}
+
+// ReadFloat32 read the texture back at full precision, rows are bottom up as stored.
+// Normalized formats read as 0 to 1.
+func (self *Texture) ReadFloat32() []float32 {
+	if self.Format.Integer {
+		values := self.ReadUint32()
+		data := make([]float32, len(values))
+		for i, v := range values {
+			data[i] = float32(v)
+		}
+
+		return data
+	}
+
+	data := make([]float32, self.Width*self.Height*self.Format.Channels)
+	self.read(gl.FLOAT, data)
+	return data
+}
+
+// ReadUint32 read an integer texture back, rows are bottom up as stored
+func (self *Texture) ReadUint32() []uint32 {
+	if !self.Format.Integer {
+		panic("Texture.ReadUint32: not an integer texture format")
+	}
+
+	data := make([]uint32, self.Width*self.Height*self.Format.Channels)
+	self.read(gl.UNSIGNED_INT, data)
+	return data
+}
+
+func (self *Texture) read(typ uint32, data interface{}) {
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.Handle)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.GetTexImage(gl.TEXTURE_2D, 0, self.Format.Format, typ, gl.Ptr(data))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+}
+
+// Resize RGBA8 textures are rescaled from Image, others on the gpu.
+// options replace the texture's Options when given.
+func (self *Texture) Resize(width, height int, options ...TextureOptions) *Texture {
+	if len(options) > 0 {
+		self.Options = textureOptions(options)
+	}
+
+	if self.Image == nil {
+		self.resizeStorage(width, height)
+	} else {
+		self.resizeImage(width, height)
+	}
+
+	self.mipmapped = false
+	return self.SetOptions(self.Options)
+}
+
+func (self *Texture) resizeImage(width, height int) {
 	// Create new dst image
 	dst := image.NewRGBA(image.Rect(0, 0, width, height))
 
@@ -51,9 +326,10 @@ func (self *Texture) Resize(width, height int) *Texture {
 
 	// Override
 	self.Image = dst
+	self.Width, self.Height = width, height
 
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, self.Handle)
+	defer State.Push().Pop()
+	State.BindTexture(gl.TEXTURE0, self.Handle)
 	gl.TexImage2D(
 		gl.TEXTURE_2D,
 		0,
@@ -64,18 +340,37 @@ func (self *Texture) Resize(width, height int) *Texture {
 		gl.RGBA,
 		gl.UNSIGNED_BYTE,
 		gl.Ptr(dst.Pix))
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, LastActiveTexture0)
+}
 
-	return self
+func (self *Texture) resizeStorage(width, height int) {
+	tex := NewTexture(width, height, self.Format.Internal, self.Options)
+	blitTexture(self, tex, width, height)
+
+	State.DeleteTextures(self.Handle)
+	self.Handle = tex.Handle
+	self.Width, self.Height = width, height
 }
 
-func (self *Texture) Activate(tex uint32) *Texture {
-	gl.ActiveTexture(tex)
-	gl.BindTexture(gl.TEXTURE_2D, self.Handle)
-	if tex == gl.TEXTURE0 {
-		LastActiveTexture0 = self.Handle
-	}
+// blitTexture copy src into the bottom left width x height of dst, scaled with nearest filtering
+func blitTexture(src, dst *Texture, width, height int) {
+	var fbos [2]uint32
+	gl.GenFramebuffers(2, &fbos[0])
+	defer State.DeleteFramebuffers(fbos[:]...)
+	defer State.Push().Pop()
+
+	State.BindFramebuffer(gl.READ_FRAMEBUFFER, fbos[0])
+	gl.FramebufferTexture2D(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, src.Handle, 0)
+	State.BindFramebuffer(gl.DRAW_FRAMEBUFFER, fbos[1])
+	gl.FramebufferTexture2D(gl.DRAW_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, dst.Handle, 0)
 
+	gl.BlitFramebuffer(
+		0, 0, int32(src.Width), int32(src.Height),
+		0, 0, int32(width), int32(height),
+		gl.COLOR_BUFFER_BIT, gl.NEAREST,
+	)
+}
+
+func (self *Texture) Activate(tex uint32) *Texture {
+	State.BindTexture(tex, self.Handle)
 	return self
 }
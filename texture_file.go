@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	_ "golang.org/x/image/bmp"
+)
+
+// LoadTextureFile decode a png, jpeg, gif, bmp, hdr or exr file into a texture, see DecodeTexture
+func LoadTextureFile(name string, options ...TextureOptions) (*Texture, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tex, err := DecodeTexture(f, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	return tex, nil
+}
+
+// LoadTextureFS decode an image file from a filesystem such as assets.ShaderFS, see DecodeTexture
+func LoadTextureFS(fsys fs.FS, name string, options ...TextureOptions) (*Texture, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tex, err := DecodeTexture(f, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	return tex, nil
+}
+
+// DecodeTexture decode an image into a texture of a matching format. Gray images are stored
+// in one channel sampled as gray, 16 bit and hdr images as floats, and everything else as RGBA8
+// with straight alpha. Set TextureOptions.FlipY to put the top row of the image at v = 1.
+func DecodeTexture(r io.Reader, options ...TextureOptions) (*Texture, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := textureOptions(options)
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	var internalFormat int32
+	var data interface{}
+	gray := false
+	switch src := img.(type) {
+	case *HDRImage:
+		pix := make([]float32, 0, 4*width*height)
+		for y := 0; y < height; y++ {
+			row := y
+			if opts.FlipY {
+				row = height - 1 - y
+			}
+
+			start := src.PixOffset(b.Min.X, b.Min.Y+row)
+			pix = append(pix, src.Pix[start:start+4*width]...)
+		}
+
+		internalFormat, data = gl.RGBA32F, pix
+	case *image.Gray:
+		dst := image.NewGray(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+		if opts.FlipY {
+			flipRows(dst.Pix, dst.Stride)
+		}
+
+		internalFormat, data, gray = gl.R8, dst.Pix, true
+	case *image.Gray16:
+		dst := image.NewGray16(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+		if opts.FlipY {
+			flipRows(dst.Pix, dst.Stride)
+		}
+
+		internalFormat, data, gray = gl.R32F, dst, true
+	case *image.RGBA64, *image.NRGBA64:
+		dst := image.NewNRGBA64(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+		if opts.FlipY {
+			flipRows(dst.Pix, dst.Stride)
+		}
+
+		pix := make([]float32, len(dst.Pix)/2)
+		for i := range pix {
+			pix[i] = float32(uint16(dst.Pix[i*2])<<8|uint16(dst.Pix[i*2+1])) / 0xffff
+		}
+
+		internalFormat, data = gl.RGBA32F, pix
+	default:
+		dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+		if opts.FlipY {
+			flipRows(dst.Pix, dst.Stride)
+		}
+
+		internalFormat, data = gl.RGBA8, dst
+	}
+
+	tex, err := LoadTextureData(width, height, internalFormat, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if gray {
+		// sample the one channel as gray
+		State.Push()
+		State.BindTexture(gl.TEXTURE0, tex.Handle)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_G, gl.RED)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_B, gl.RED)
+		State.Pop()
+	}
+
+	return tex, nil
+}
+
+// flipRows reverse the order of the rows of an image's pixels in place
+func flipRows(pix []byte, stride int) {
+	rows := len(pix) / stride
+	row := make([]byte, stride)
+	for y := 0; y < rows/2; y++ {
+		top := pix[y*stride : (y+1)*stride]
+		bottom := pix[(rows-1-y)*stride : (rows-y)*stride]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+}
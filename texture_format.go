@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// TextureFormat how texels are stored on the gpu and transferred to and from go
+type TextureFormat struct {
+	Internal int32
+	// Format and Type of pixel transfers at full precision
+	Format uint32
+	Type   uint32
+	// Channels per texel
+	Channels int
+	// Float stored as floats, Integer read unnormalized by usampler
+	Float   bool
+	Integer bool
+}
+
+var (
+	FormatR8      = TextureFormat{gl.R8, gl.RED, gl.UNSIGNED_BYTE, 1, false, false}
+	FormatRG8     = TextureFormat{gl.RG8, gl.RG, gl.UNSIGNED_BYTE, 2, false, false}
+	FormatRGBA8   = TextureFormat{gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE, 4, false, false}
+	FormatR16F    = TextureFormat{gl.R16F, gl.RED, gl.HALF_FLOAT, 1, true, false}
+	FormatRG16F   = TextureFormat{gl.RG16F, gl.RG, gl.HALF_FLOAT, 2, true, false}
+	FormatRGBA16F = TextureFormat{gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT, 4, true, false}
+	FormatR32F    = TextureFormat{gl.R32F, gl.RED, gl.FLOAT, 1, true, false}
+	FormatRGBA32F = TextureFormat{gl.RGBA32F, gl.RGBA, gl.FLOAT, 4, true, false}
+	FormatR32UI   = TextureFormat{gl.R32UI, gl.RED_INTEGER, gl.UNSIGNED_INT, 1, false, true}
+
+	// depth formats for framebuffer attachments shaders can sample
+	FormatDepth24         = TextureFormat{gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, 1, false, false}
+	FormatDepth32F        = TextureFormat{gl.DEPTH_COMPONENT32F, gl.DEPTH_COMPONENT, gl.FLOAT, 1, true, false}
+	FormatDepth24Stencil8 = TextureFormat{gl.DEPTH24_STENCIL8, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, 1, false, false}
+)
+
+var textureFormats = []TextureFormat{
+	FormatR8, FormatRG8, FormatRGBA8,
+	FormatR16F, FormatRG16F, FormatRGBA16F,
+	FormatR32F, FormatRGBA32F,
+	FormatR32UI,
+	FormatDepth24, FormatDepth32F, FormatDepth24Stencil8,
+}
+
+// LookupTextureFormat find a supported format by internal format, gl.RGBA is taken as gl.RGBA8
+func LookupTextureFormat(internalFormat int32) (TextureFormat, bool) {
+	if internalFormat == gl.RGBA {
+		return FormatRGBA8, true
+	}
+
+	for _, f := range textureFormats {
+		if f.Internal == internalFormat {
+			return f, true
+		}
+	}
+
+	return TextureFormat{}, false
+}
+
+// attachment framebuffer attachment point of depth formats, 0 for color formats
+func (self TextureFormat) attachment() uint32 {
+	switch self.Format {
+	case gl.DEPTH_COMPONENT:
+		return gl.DEPTH_ATTACHMENT
+	case gl.DEPTH_STENCIL:
+		return gl.DEPTH_STENCIL_ATTACHMENT
+	}
+
+	return 0
+}
+
+var textureFormatNames = map[int32]string{
+	gl.R8: "R8", gl.RG8: "RG8", gl.RGBA8: "RGBA8",
+	gl.R16F: "R16F", gl.RG16F: "RG16F", gl.RGBA16F: "RGBA16F",
+	gl.R32F: "R32F", gl.RGBA32F: "RGBA32F",
+	gl.R32UI:             "R32UI",
+	gl.DEPTH_COMPONENT24: "DEPTH_COMPONENT24", gl.DEPTH_COMPONENT32F: "DEPTH_COMPONENT32F", gl.DEPTH24_STENCIL8: "DEPTH24_STENCIL8",
+}
+
+// ParseTextureFormat find a supported format by name such as RGBA8 or rgba32f, as String prints it
+func ParseTextureFormat(name string) (TextureFormat, bool) {
+	for internal, n := range textureFormatNames {
+		if strings.EqualFold(n, name) {
+			return LookupTextureFormat(internal)
+		}
+	}
+
+	return TextureFormat{}, false
+}
+
+func (self TextureFormat) String() string {
+	if name, ok := textureFormatNames[self.Internal]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%x", self.Internal)
+}
+
+func mustTextureFormat(internalFormat int32) TextureFormat {
+	f, ok := LookupTextureFormat(internalFormat)
+	if !ok {
+		panic(fmt.Sprintf("unsupported texture internal format 0x%x", internalFormat))
+	}
+
+	return f
+}
+
+// transfer the format and type data is uploaded with, and a pointer to it.
+// Slices are tightly packed texels with the texture's channels, []uint16 holds half floats for float formats.
+func (self TextureFormat) transfer(data interface{}) (uint32, uint32, interface{}, int, error) {
+	format := self.Format
+	if self.Integer {
+		switch data.(type) {
+		case []uint32, []int32:
+		default:
+			return 0, 0, nil, 0, fmt.Errorf("integer textures need []uint32 or []int32 data, not %T", data)
+		}
+	}
+
+	switch d := data.(type) {
+	case []uint8:
+		return format, gl.UNSIGNED_BYTE, d, len(d) / self.Channels, nil
+	case []uint16:
+		if self.Float {
+			return format, gl.HALF_FLOAT, d, len(d) / self.Channels, nil
+		}
+
+		return format, gl.UNSIGNED_SHORT, d, len(d) / self.Channels, nil
+	case []float32:
+		return format, gl.FLOAT, d, len(d) / self.Channels, nil
+	case []uint32:
+		return format, gl.UNSIGNED_INT, d, len(d) / self.Channels, nil
+	case []int32:
+		return format, gl.INT, d, len(d) / self.Channels, nil
+	case *image.RGBA:
+		return gl.RGBA, gl.UNSIGNED_BYTE, d.Pix, len(d.Pix) / 4, nil
+	case *image.NRGBA:
+		return gl.RGBA, gl.UNSIGNED_BYTE, d.Pix, len(d.Pix) / 4, nil
+	case *image.Gray:
+		return gl.RED, gl.UNSIGNED_BYTE, d.Pix, len(d.Pix), nil
+	case *image.Gray16:
+		// Pix is big endian
+		pix := make([]uint16, len(d.Pix)/2)
+		for i := range pix {
+			pix[i] = binary.BigEndian.Uint16(d.Pix[i*2:])
+		}
+
+		return gl.RED, gl.UNSIGNED_SHORT, pix, len(pix), nil
+	case *image.RGBA64:
+		pix := make([]uint16, len(d.Pix)/2)
+		for i := range pix {
+			pix[i] = binary.BigEndian.Uint16(d.Pix[i*2:])
+		}
+
+		return gl.RGBA, gl.UNSIGNED_SHORT, pix, len(pix) / 4, nil
+	}
+
+	return 0, 0, nil, 0, fmt.Errorf("unsupported texture data %T", data)
+}
+
+// Float16 convert a float32 to half float bits, for []uint16 texture data
+func Float16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign
+	case bits>>23&0xff == 0xff:
+		// inf and nan
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+
+		return sign | 0x7c00
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		// subnormal, or too small
+		if exp < -10 {
+			return sign
+		}
+
+		mantissa |= 0x800000
+		return sign | uint16(roundShift(mantissa, uint(14-exp)))
+	}
+
+	// rounding up can carry into the exponent, or on to inf
+	return sign | uint16(uint32(exp)<<10+roundShift(mantissa, 13))
+}
+
+// roundShift shift bits right rounding to nearest, ties to even
+func roundShift(bits uint32, shift uint) uint32 {
+	shifted := bits >> shift
+	rest, half := bits&(1<<shift-1), uint32(1)<<(shift-1)
+	if rest > half || (rest == half && shifted&1 == 1) {
+		shifted++
+	}
+
+	return shifted
+}
+
+// Float32 convert half float bits to a float32
+func Float32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mantissa := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// subnormal
+		f := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+
+		return f
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	}
+
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mantissa<<13)
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat16(t *testing.T) {
+	ulp := float32(math.Ldexp(1, -10))
+	tests := []struct {
+		name string
+		f    float32
+		want uint16
+	}{
+		{"one", 1, 0x3c00},
+		{"just below half an ulp", 1 + ulp/2 - ulp/1024, 0x3c00},
+		{"half an ulp ties to even down", 1 + ulp/2, 0x3c00},
+		{"just above half an ulp", 1 + ulp/2 + ulp/1024, 0x3c01},
+		{"half an ulp ties to even up", 1 + 3*ulp/2, 0x3c02},
+		{"negative", -(1 + ulp/2 + ulp/1024), 0xbc01},
+		{"carries into the exponent", 2 - ulp/4, 0x4000},
+		{"largest half", 65504, 0x7bff},
+		{"just below rounding to inf", 65519, 0x7bff},
+		{"rounds to inf", 65520, 0x7c00},
+		{"inf", float32(math.Inf(1)), 0x7c00},
+		{"nan", float32(math.NaN()), 0x7e00},
+		{"smallest subnormal", float32(math.Ldexp(1, -24)), 0x0001},
+		{"subnormal tie to even", float32(math.Ldexp(3, -25)), 0x0002},
+		{"half the smallest subnormal ties to zero", float32(math.Ldexp(1, -25)), 0},
+		{"just above half the smallest subnormal", float32(math.Ldexp(1, -25) * 1.001), 0x0001},
+		{"subnormal carries to normal", float32(math.Ldexp(2047, -25)), 0x0400},
+		{"too small", float32(math.Ldexp(1, -30)), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Float16(tt.f); got != tt.want {
+				t.Errorf("Float16(%v) = 0x%04x, want 0x%04x", tt.f, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFloat16Nearest Float32(Float16(x)) is the nearest half, no neighbour is closer
+func TestFloat16Nearest(t *testing.T) {
+	for i := 0; i < 100000; i++ {
+		// spread over the subnormal and normal range of halfs
+		x := float32(math.Ldexp(1+float64(i%997)/997, i%40-26))
+		h := Float16(x)
+		d := math.Abs(float64(x - Float32(h)))
+		for _, n := range []uint16{h - 1, h + 1} {
+			if n&0x7fff >= 0x7c00 || n&0x7fff == 0x7fff {
+				continue
+			}
+
+			if nd := math.Abs(float64(x - Float32(n))); nd < d {
+				t.Fatalf("Float16(%v) = %v, %v is closer", x, Float32(h), Float32(n))
+			}
+		}
+	}
+}
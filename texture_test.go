@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"image"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// TestTextureAnisotropy requested samples are clamped to the driver's range, or ignored without the extension
+func TestTextureAnisotropy(t *testing.T) {
+	withGLContext(t)
+
+	for _, samples := range []float32{0.5, 4, 1e6} {
+		tex := LoadTexture(image.NewRGBA(image.Rect(0, 0, 2, 2)), TextureOptions{Anisotropy: samples})
+
+		if code := gl.GetError(); code != gl.NO_ERROR {
+			t.Errorf("anisotropy %v: gl error 0x%x", samples, code)
+		}
+
+		if max := maxAnisotropy(); max > 0 {
+			var got float32
+			State.BindTexture(gl.TEXTURE0, tex.Handle)
+			gl.GetTexParameterfv(gl.TEXTURE_2D, gl.TEXTURE_MAX_ANISOTROPY, &got)
+			if got < 1 || got > max {
+				t.Errorf("anisotropy %v set as %v, driver range is 1 to %v", samples, got, max)
+			}
+		}
+
+		State.DeleteTextures(tex.Handle)
+	}
+}
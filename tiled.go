@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Tile region of a larger image being rendered, in pixels from the bottom left
+type Tile struct {
+	X, Y          int
+	Width, Height int
+
+	// size of the whole image
+	FullWidth, FullHeight int
+}
+
+// NewTile a single tile covering the whole image
+func NewTile(width, height int) Tile {
+	return Tile{
+		Width: width, Height: height,
+		FullWidth: width, FullHeight: height,
+	}
+}
+
+// TiledProgram is implemented by programs that can render part of an image larger than the screen.
+// RenderTile draws the tile to ScreenFramebuffer at tile size, fragment coordinate
+// shaders should add the tile offset to gl_FragCoord and scale by the full size.
+type TiledProgram interface {
+	RenderTile(t float64, tile Tile)
+}
+
+// CaptureTiled render the program at width x height in TileSize tiles and stitch them into a png.
+// Only one tile is on the gpu and one row of tiles in memory at a time.
+func (self *Renderer) CaptureTiled(width, height int) error {
+	program, ok := self.Program.(TiledProgram)
+	if !ok {
+		return fmt.Errorf("%v can not render tiles", ProgramName(self.Program))
+	}
+
+	if width <= 0 || height <= 0 {
+		return errors.New("tiled capture size must be positive")
+	}
+
+	folder := "screencaptures/posters/"
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+
+	name := folder + time.Now().Format("20060102150405") + ".png"
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	meta := NewCaptureMetadata(self)
+	meta.Width, meta.Height = width, height
+
+	enc, err := newPNGStreamEncoder(f, width, height, meta)
+	if err != nil {
+		return err
+	}
+
+	size := self.TileSize
+	if size <= 0 {
+		size = 1024
+	}
+
+	tw, th := size, size
+	if width < tw {
+		tw = width
+	}
+
+	if height < th {
+		th = height
+	}
+
+	// render tiles to their own framebuffer, leave the screen alone
+	screen := ScreenFramebuffer
+	target := NewHeadlessSurface(tw, th, 0)
+	defer func() {
+		target.Cleanup()
+		ScreenFramebuffer = screen
+		gl.Viewport(0, 0, int32(self.Width), int32(self.Height))
+	}()
+
+	fmt.Printf("Saving %v (%v x %v in %v x %v tiles)\n", name, width, height, tw, th)
+
+	t := 0.0
+	if self.Clock != nil {
+		t = self.Clock.Time()
+	}
+
+	strip := make([]byte, width*th*4)
+	for y := 0; y < height; y += th {
+		rows := th
+		if y+rows > height {
+			rows = height - y
+		}
+
+		for x := 0; x < width; x += tw {
+			cols := tw
+			if x+cols > width {
+				cols = width - x
+			}
+
+			tile := Tile{
+				X: x, Y: y,
+				Width: cols, Height: rows,
+				FullWidth: width, FullHeight: height,
+			}
+
+			State.BindFramebuffer(gl.FRAMEBUFFER, target.Framebuffer.Handle)
+			gl.Viewport(0, 0, int32(cols), int32(rows))
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+			program.RenderTile(t, tile)
+
+			// read straight into the strip, rows are full width apart
+			State.BindFramebuffer(gl.READ_FRAMEBUFFER, target.Framebuffer.Handle)
+			gl.PixelStorei(gl.PACK_ROW_LENGTH, int32(width))
+			gl.ReadPixels(
+				0, 0,
+				int32(cols), int32(rows),
+				gl.RGBA,
+				gl.UNSIGNED_BYTE,
+				gl.Ptr(strip[x*4:]),
+			)
+			gl.PixelStorei(gl.PACK_ROW_LENGTH, 0)
+		}
+
+		// rows are written in the order read, matching Capture
+		for row := 0; row < rows; row++ {
+			if err = enc.WriteRow(strip[row*width*4 : (row+1)*width*4]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = enc.Close(); err != nil {
+		return err
+	}
+
+	return notify("Poster Captured!", name, "applet.icns")
+}
+
+// pngStreamEncoder writes an rgba png a row at a time
+type pngStreamEncoder struct {
+	w    *bufio.Writer
+	idat *pngChunkWriter
+	z    *zlib.Writer
+
+	prev, filtered []byte
+}
+
+func newPNGStreamEncoder(w io.Writer, width, height int, meta CaptureMetadata) (*pngStreamEncoder, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("\x89PNG\r\n\x1a\n"); err != nil {
+		return nil, err
+	}
+
+	// 8 bit rgba, no interlace
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8] = 8
+	ihdr[9] = 6
+	writePNGChunk(bw, "IHDR", ihdr)
+
+	if err := writeCaptureMetadata(bw, meta); err != nil {
+		return nil, err
+	}
+
+	enc := &pngStreamEncoder{
+		w:        bw,
+		idat:     &pngChunkWriter{w: bw, name: "IDAT"},
+		prev:     make([]byte, width*4),
+		filtered: make([]byte, 5*(1+width*4)),
+	}
+
+	enc.z, _ = zlib.NewWriterLevel(enc.idat, zlib.DefaultCompression)
+	return enc, nil
+}
+
+func (self *pngStreamEncoder) WriteRow(row []byte) error {
+	if _, err := self.z.Write(filterPNGRow(row, self.prev, self.filtered)); err != nil {
+		return err
+	}
+
+	copy(self.prev, row)
+	return nil
+}
+
+func (self *pngStreamEncoder) Close() error {
+	if err := self.z.Close(); err != nil {
+		return err
+	}
+
+	if err := self.idat.Flush(); err != nil {
+		return err
+	}
+
+	writePNGChunk(self.w, "IEND", nil)
+	return self.w.Flush()
+}
+
+// pngChunkWriter buffers writes into chunks of up to 64KB
+type pngChunkWriter struct {
+	w    io.Writer
+	name string
+	buf  []byte
+}
+
+func (self *pngChunkWriter) Write(p []byte) (int, error) {
+	self.buf = append(self.buf, p...)
+	if len(self.buf) >= 1<<16 {
+		if err := self.Flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (self *pngChunkWriter) Flush() error {
+	if len(self.buf) == 0 {
+		return nil
+	}
+
+	writePNGChunk(self.w, self.name, self.buf)
+	self.buf = self.buf[:0]
+	return nil
+}
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// VertexAttribute where one shader input is read from a vertex buffer. Offset and Stride are in bytes.
+type VertexAttribute struct {
+	Location uint32
+	// Components 1 to 4
+	Components int32
+	// Type of each component, gl.FLOAT when 0
+	Type uint32
+	// Normalized fixed point components read as 0 to 1 or -1 to 1
+	Normalized bool
+	// Integer components read unconverted by int and uint inputs
+	Integer bool
+	Offset  int
+	// Stride between vertices, 0 when tightly packed
+	Stride int32
+	// Divisor advance once per this many instances instead of per vertex
+	Divisor uint32
+}
+
+// VertexLayout the attributes of a vertex buffer
+type VertexLayout []VertexAttribute
+
+// InterleavedLayout float attributes at locations 0 and up, stored one vertex after another
+func InterleavedLayout(components ...int32) VertexLayout {
+	layout := make(VertexLayout, len(components))
+	offset := 0
+	for i, c := range components {
+		layout[i] = VertexAttribute{Location: uint32(i), Components: c, Offset: offset}
+		offset += int(c) * F32_SIZE
+	}
+
+	for i := range layout {
+		layout[i].Stride = int32(offset)
+	}
+
+	return layout
+}
+
+var (
+	// LayoutP2T2 2d position and texture coordinates, QuadVertices
+	LayoutP2T2 = InterleavedLayout(2, 2)
+	// LayoutP3T2N3 position, texture coordinates and normal, CubeAltVertices
+	LayoutP3T2N3 = InterleavedLayout(3, 2, 3)
+	// LayoutP4T2 homogeneous position and texture coordinates padded to 8 floats, CubeVertices
+	LayoutP4T2 = VertexLayout{
+		{Location: 0, Components: 4, Stride: 8 * F32_SIZE32},
+		{Location: 1, Components: 2, Offset: 4 * F32_SIZE, Stride: 8 * F32_SIZE32},
+	}
+)
+
+// stride bytes between an attribute's vertices
+func (self VertexAttribute) stride() int32 {
+	if self.Stride != 0 {
+		return self.Stride
+	}
+
+	return self.Components * int32(vertexTypeSize(self.Type))
+}
+
+func (self VertexAttribute) glType() uint32 {
+	if self.Type == 0 {
+		return gl.FLOAT
+	}
+
+	return self.Type
+}
+
+func vertexTypeSize(typ uint32) int {
+	switch typ {
+	case 0, gl.FLOAT, gl.INT, gl.UNSIGNED_INT:
+		return 4
+	case gl.HALF_FLOAT, gl.SHORT, gl.UNSIGNED_SHORT:
+		return 2
+	case gl.BYTE, gl.UNSIGNED_BYTE:
+		return 1
+	}
+
+	return 0
+}
+
+// Vertices the number of vertices a buffer of size bytes holds, an error when an attribute
+// does not fit. Interleaved layouts, with one stride for every attribute, need whole vertices.
+// Layouts of only instanced attributes count instances.
+func (self VertexLayout) Vertices(size int) (int, error) {
+	if len(self) == 0 {
+		return 0, fmt.Errorf("vertex layout has no attributes")
+	}
+
+	var maxAttribs int32
+	gl.GetIntegerv(gl.MAX_VERTEX_ATTRIBS, &maxAttribs)
+
+	interleaved := true
+	count, instances := -1, -1
+	locations := map[uint32]bool{}
+	for _, a := range self {
+		typeSize := vertexTypeSize(a.Type)
+		switch {
+		case a.Location >= uint32(maxAttribs):
+			return 0, fmt.Errorf("vertex attribute %v: at most %v locations are supported", a.Location, maxAttribs)
+		case locations[a.Location]:
+			return 0, fmt.Errorf("vertex attribute %v: location used twice", a.Location)
+		case a.Components < 1 || a.Components > 4:
+			return 0, fmt.Errorf("vertex attribute %v: %v components, 1 to 4 are supported", a.Location, a.Components)
+		case typeSize == 0:
+			return 0, fmt.Errorf("vertex attribute %v: unsupported type 0x%x", a.Location, a.Type)
+		case a.Integer && (a.glType() == gl.FLOAT || a.glType() == gl.HALF_FLOAT):
+			return 0, fmt.Errorf("vertex attribute %v: integer attributes need an integer type", a.Location)
+		case a.Offset < 0 || a.Offset%typeSize != 0 || a.stride()%int32(typeSize) != 0:
+			return 0, fmt.Errorf("vertex attribute %v: offset %v and stride %v are not aligned to its type", a.Location, a.Offset, a.stride())
+		}
+
+		locations[a.Location] = true
+		interleaved = interleaved && a.Stride != 0 && a.Stride == self[0].Stride
+
+		width := int(a.Components) * typeSize
+		if int(a.stride()) < width {
+			return 0, fmt.Errorf("vertex attribute %v: %v bytes overflow a stride of %v", a.Location, width, a.stride())
+		}
+
+		// the last vertex only needs room for its own components
+		if a.Offset+width > size {
+			return 0, fmt.Errorf("vertex attribute %v: offset %v is past the end of %v bytes", a.Location, a.Offset, size)
+		}
+
+		n := (size-a.Offset-width)/int(a.stride()) + 1
+		if a.Divisor == 0 && (count < 0 || n < count) {
+			count = n
+		} else if a.Divisor != 0 && (instances < 0 || n < instances) {
+			instances = n
+		}
+	}
+
+	if count < 0 {
+		count = instances
+	}
+
+	if !interleaved {
+		return count, nil
+	}
+
+	stride := int(self[0].Stride)
+	for _, a := range self {
+		if a.Offset+int(a.Components)*vertexTypeSize(a.Type) > stride {
+			return 0, fmt.Errorf("vertex attribute %v: offset %v overflows a %v byte vertex", a.Location, a.Offset, stride)
+		}
+	}
+
+	if size%stride != 0 {
+		return 0, fmt.Errorf("vertex data of %v bytes is not a whole number of %v byte vertices", size, stride)
+	}
+
+	return size / stride, nil
+}
+
+// apply point the bound vertex array's attributes at the bound array buffer
+func (self VertexLayout) apply() {
+	self.applyAt(0)
+}
+
+// applyAt point the bound vertex array's attributes at base bytes into the bound array buffer
+func (self VertexLayout) applyAt(base int) {
+	for _, a := range self {
+		offset := uintptr(base + a.Offset)
+		gl.EnableVertexAttribArray(a.Location)
+		if a.Integer {
+			gl.VertexAttribIPointerWithOffset(a.Location, a.Components, a.glType(), a.stride(), offset)
+		} else {
+			gl.VertexAttribPointerWithOffset(a.Location, a.Components, a.glType(), a.Normalized, a.stride(), offset)
+		}
+
+		gl.VertexAttribDivisor(a.Location, a.Divisor)
+	}
+}
+
+// newVertexArray upload vertices to a new array buffer with usage, e.g. gl.STATIC_DRAW, and
+// describe them with layout in a new vertex array, which is left bound
+func newVertexArray(vertices Vertices, layout VertexLayout, usage uint32) (vao, vbo uint32, count int, err error) {
+	if len(vertices) == 0 {
+		return 0, 0, 0, fmt.Errorf("vertex data is empty")
+	}
+
+	count, err = layout.Vertices(vertices.BufferSize())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	gl.GenVertexArrays(1, &vao)
+	State.BindVertexArray(vao)
+
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, vertices.BufferSize(), gl.Ptr(vertices), usage)
+	layout.apply()
+
+	return vao, vbo, count, nil
+}
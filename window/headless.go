@@ -0,0 +1,19 @@
+package window
+
+import (
+	"fmt"
+	"plugin"
+
+	engine "gogl"
+)
+
+// HeadlessRender load a plugin and render the program it sets as HotProgram offscreen
+func HeadlessRender(pluginFile string, width, height, frames int, record bool, posterWidth, posterHeight int) error {
+	// opening the plugin runs its init, setting engine.HotProgram
+	if _, err := plugin.Open(pluginFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rendering Plug: %v (%v frames @ %v x %v)\n", pluginFile, frames, width, height)
+	return engine.HeadlessRender(width, height, frames, record, posterWidth, posterHeight)
+}
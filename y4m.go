@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Y4MEncoder writes frames to an uncompressed YUV4MPEG2 stream at a constant frame rate.
+// Planes are full range 16 bit 4:4:4, so the 8 bit rgb frames convert back exactly.
+type Y4MEncoder struct {
+	name string
+	// frame rate num/den, e.g. 30000/1001
+	num, den int
+	file     *os.File
+	w        *bufio.Writer
+
+	width, height int
+	planes        []byte
+
+	elapsed time.Duration
+	written int
+}
+
+func NewY4MEncoder(name string, width, height int, fps float64) (*Y4MEncoder, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	num, den := frameRate(fps)
+	enc := &Y4MEncoder{
+		name:   name,
+		num:    num,
+		den:    den,
+		file:   f,
+		w:      bufio.NewWriter(f),
+		width:  width,
+		height: height,
+		planes: make([]byte, 6*width*height),
+	}
+
+	_, err = fmt.Fprintf(
+		enc.w, "YUV4MPEG2 W%v H%v F%v:%v Ip A1:1 C444p16 XCOLORRANGE=FULL\n",
+		width, height, num, den,
+	)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return enc, nil
+}
+
+func (self *Y4MEncoder) Encode(img *image.RGBA, duration time.Duration) error {
+	// frames due by the end of this one
+	self.elapsed += duration
+	due := int(math.Round(self.elapsed.Seconds() * float64(self.num) / float64(self.den)))
+	if due <= self.written {
+		return nil
+	}
+
+	// little endian samples, a plane each of y, cb and cr
+	n := 2 * self.width * self.height
+	y, u, v := self.planes[:n], self.planes[n:2*n], self.planes[2*n:]
+	for row := 0; row < self.height; row++ {
+		pix := img.Pix[row*img.Stride:]
+		for col := 0; col < self.width; col++ {
+			i := 2 * (row*self.width + col)
+			py, pu, pv := y4mPixel(pix[4*col], pix[4*col+1], pix[4*col+2])
+			binary.LittleEndian.PutUint16(y[i:], py)
+			binary.LittleEndian.PutUint16(u[i:], pu)
+			binary.LittleEndian.PutUint16(v[i:], pv)
+		}
+	}
+
+	for ; self.written < due; self.written++ {
+		if _, err := self.w.WriteString("FRAME\n"); err != nil {
+			return err
+		}
+
+		if _, err := self.w.Write(self.planes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// frameRate fps as a fraction, NTSC rates like 29.97 become 30000/1001
+func frameRate(fps float64) (num, den int) {
+	if fps <= 0 {
+		return 60, 1
+	}
+
+	if n := math.Round(fps); math.Abs(fps-n) < 1e-6 {
+		return int(n), 1
+	}
+
+	if n := math.Round(fps * 1.001); math.Abs(fps*1.001-n) < 1e-3 {
+		return int(n) * 1000, 1001
+	}
+
+	num, den = int(math.Round(fps*1000)), 1000
+	a, b := num, den
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return num / a, den / a
+}
+
+// y4mPixel bt.601 full range 16 bit YCbCr of an 8 bit rgb pixel
+func y4mPixel(r, g, b byte) (y, cb, cr uint16) {
+	// 8 to 16 bit full range
+	fr, fg, fb := float64(r)*257, float64(g)*257, float64(b)*257
+	y = roundUint16(0.299*fr + 0.587*fg + 0.114*fb)
+	cb = roundUint16(32768 - 0.168736*fr - 0.331264*fg + 0.5*fb)
+	cr = roundUint16(32768 + 0.5*fr - 0.418688*fg - 0.081312*fb)
+	return y, cb, cr
+}
+
+func roundUint16(x float64) uint16 {
+	return uint16(math.Max(0, math.Min(0xffff, math.Round(x))))
+}
+
+func (self *Y4MEncoder) Close() error {
+	err := self.w.Flush()
+	if cerr := self.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (self *Y4MEncoder) Name() string {
+	return self.name
+}
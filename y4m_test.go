@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestY4MEncoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		fps       float64
+		durations []time.Duration
+		header    string
+		frames    int
+	}{
+		{"integer rate", 30, []time.Duration{time.Second / 30, time.Second / 30}, "F30:1", 2},
+		{"ntsc rate", 30000.0 / 1001, []time.Duration{time.Second}, "F30000:1001", 30},
+		{"film ntsc rate", 23.976, []time.Duration{time.Second / 24}, "F24000:1001", 1},
+		{"fractional rate", 12.5, []time.Duration{time.Second}, "F25:2", 13},
+		{"long frames repeat", 10, []time.Duration{time.Second / 2, time.Second / 10}, "F10:1", 6},
+		{"short frames drop", 10, []time.Duration{time.Second / 40, time.Second / 40, time.Second / 40}, "F10:1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "a.y4m")
+			enc, err := NewY4MEncoder(name, 2, 2, tt.fps)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+			for _, d := range tt.durations {
+				if err := enc.Encode(img, d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header := string(data[:bytes.IndexByte(data, '\n')])
+			if !bytes.Contains([]byte(header), []byte(" "+tt.header+" ")) {
+				t.Errorf("header %q, want %v", header, tt.header)
+			}
+
+			if frames := bytes.Count(data, []byte("FRAME\n")); frames != tt.frames {
+				t.Errorf("%v frames, want %v", frames, tt.frames)
+			}
+		})
+	}
+}
+
+func TestY4MColors(t *testing.T) {
+	tests := []struct {
+		rgb     [3]byte
+		y, u, v uint16
+	}{
+		{[3]byte{0, 0, 0}, 0, 32768, 32768},
+		{[3]byte{255, 255, 255}, 65535, 32768, 32768},
+		{[3]byte{255, 0, 0}, 19595, 21710, 65535},
+		{[3]byte{0, 0, 255}, 7471, 65535, 27439},
+	}
+
+	for _, tt := range tests {
+		name := filepath.Join(t.TempDir(), "a.y4m")
+		enc, err := NewY4MEncoder(name, 1, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		copy(img.Pix, tt.rgb[:])
+		enc.Encode(img, time.Second)
+		enc.Close()
+
+		data, _ := os.ReadFile(name)
+		yuv := data[len(data)-6:]
+		y, u, v := binary.LittleEndian.Uint16(yuv), binary.LittleEndian.Uint16(yuv[2:]), binary.LittleEndian.Uint16(yuv[4:])
+		if y != tt.y || u != tt.u || v != tt.v {
+			t.Errorf("rgb %v: yuv %v %v %v, want %v %v %v", tt.rgb, y, u, v, tt.y, tt.u, tt.v)
+		}
+	}
+}
+
+// y4mRGB the 8 bit rgb a full range 16 bit YCbCr sample converts back to
+func y4mRGB(y, u, v uint16) [3]byte {
+	channel := func(x float64) byte {
+		return byte(math.Round(math.Max(0, math.Min(65535, x)) / 257))
+	}
+
+	fy, fu, fv := float64(y), float64(u)-32768, float64(v)-32768
+	return [3]byte{channel(fy + 1.402*fv), channel(fy - 0.344136*fu - 0.714136*fv), channel(fy + 1.772*fu)}
+}
+
+// TestY4MLossless frames decode back to the rgb encoded, and so does every 8 bit color
+func TestY4MLossless(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.y4m")
+	enc, err := NewY4MEncoder(name, 256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := i / 4
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = byte(p), byte(p>>8), byte(p*7+(p>>8)*13), 255
+	}
+
+	enc.Encode(img, time.Second)
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 256 * 256
+	planes := data[len(data)-6*n:]
+	for p := 0; p < n; p++ {
+		y := binary.LittleEndian.Uint16(planes[2*p:])
+		u := binary.LittleEndian.Uint16(planes[2*(n+p):])
+		v := binary.LittleEndian.Uint16(planes[2*(2*n+p):])
+		if got, want := y4mRGB(y, u, v), [3]byte{img.Pix[4*p], img.Pix[4*p+1], img.Pix[4*p+2]}; got != want {
+			t.Fatalf("pixel %v decodes as %v, want %v", p, got, want)
+		}
+	}
+
+	for c := 0; c < 1<<24; c++ {
+		want := [3]byte{byte(c >> 16), byte(c >> 8), byte(c)}
+		if got := y4mRGB(y4mPixel(want[0], want[1], want[2])); got != want {
+			t.Fatalf("rgb %v converts back as %v", want, got)
+		}
+	}
+}